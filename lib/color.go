@@ -0,0 +1,285 @@
+package meta
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+)
+
+// ColorSpace is a space of three channels between which the colors are converted. All the channels are in [0, 1],
+// except for Lab and the chroma of YCbCr.
+type ColorSpace int
+
+const (
+	// RGB is sRGB, with its gamma.
+	RGB ColorSpace = iota
+	// LinearRGB is sRGB without the gamma, proportional to the light.
+	LinearRGB
+	// HSV is the hue, in [0, 1) around the circle of colors, the saturation and the value.
+	HSV
+	// YCbCr is the full range BT.601 luma and chroma of JPEG, Cb and Cr are in [-0.5, 0.5].
+	YCbCr
+	// Lab is CIE L*a*b* under the D65 white. L is in [0, 100], a and b roughly in [-128, 127].
+	Lab
+)
+
+var colorSpaceNames = []string{"rgb", "linear-rgb", "hsv", "ycbcr", "lab"}
+
+// ColorSpaceByName parses the names of the color spaces.
+func ColorSpaceByName(name string) (ColorSpace, error) {
+	for i, spaceName := range colorSpaceNames {
+		if spaceName == name {
+			return ColorSpace(i), nil
+		}
+	}
+	return 0, errors.Errorf("unknown color space '%v', expected one of %v", name, strings.Join(colorSpaceNames, ", "))
+}
+
+// ColorSpaceNames returns the names accepted by ColorSpaceByName.
+func ColorSpaceNames() []string {
+	return append([]string(nil), colorSpaceNames...)
+}
+
+func (s ColorSpace) String() string {
+	if s < 0 || int(s) >= len(colorSpaceNames) {
+		return fmt.Sprintf("ColorSpace(%d)", int(s))
+	}
+	return colorSpaceNames[s]
+}
+
+// The D65 white of sRGB in CIE XYZ.
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+var (
+	linearOnce  sync.Once
+	linearTable []float64
+)
+
+// SRGBToLinear removes the gamma of a sRGB value in [0, 1].
+func SRGBToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v + 0.055) / 1.055, 2.4)
+}
+
+// LinearToSRGB applies the gamma of sRGB on a linear value in [0, 1].
+func LinearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055 * math.Pow(v, 1 / 2.4) - 0.055
+}
+
+// linear16 is SRGBToLinear on a 16 bit value, from a table computed once.
+func linear16(v uint32) float64 {
+	linearOnce.Do(func() {
+		linearTable = make([]float64, 65536)
+		for i := range linearTable {
+			linearTable[i] = SRGBToLinear(float64(i) / 65535)
+		}
+	})
+	return linearTable[v]
+}
+
+// LinearRGBToLab converts a linear RGB color, with values in [0, 1], to CIE Lab.
+func LinearRGBToLab(r float64, g float64, b float64) (float64, float64, float64) {
+	x := (0.4124564 * r + 0.3575761 * g + 0.1804375 * b) / whiteX
+	y := (0.2126729 * r + 0.7151522 * g + 0.0721750 * b) / whiteY
+	z := (0.0193339 * r + 0.1191920 * g + 0.9503041 * b) / whiteZ
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116 * fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// LabToLinearRGB undoes LinearRGBToLab, the colors out of the gamut of sRGB are not clamped.
+func LabToLinearRGB(l float64, a float64, b float64) (float64, float64, float64) {
+	fy := (l + 16) / 116
+	x := whiteX * labFInverse(fy + a / 500)
+	y := whiteY * labFInverse(fy)
+	z := whiteZ * labFInverse(fy - b / 200)
+
+	return 3.2404542 * x - 1.5371385 * y - 0.4985314 * z,
+		-0.9692660 * x + 1.8760108 * y + 0.0415560 * z,
+		0.0556434 * x - 0.2040259 * y + 1.0572252 * z
+}
+
+const labDelta = 6.0 / 29
+
+func labF(t float64) float64 {
+	if t > labDelta * labDelta * labDelta {
+		return math.Cbrt(t)
+	}
+	return t / (3 * labDelta * labDelta) + 4.0 / 29
+}
+
+func labFInverse(t float64) float64 {
+	if t > labDelta {
+		return t * t * t
+	}
+	return 3 * labDelta * labDelta * (t - 4.0 / 29)
+}
+
+// RGBToHSV converts a sRGB color to HSV, the hue of the grays is 0.
+func RGBToHSV(r float64, g float64, b float64) (float64, float64, float64) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	chroma := max - min
+
+	var h float64
+	switch {
+	case chroma == 0:
+		h = 0
+	case max == r:
+		h = (g - b) / chroma
+		if h < 0 {
+			h += 6
+		}
+	case max == g:
+		h = (b - r) / chroma + 2
+	default:
+		h = (r - g) / chroma + 4
+	}
+
+	s := float64(0)
+	if max > 0 {
+		s = chroma / max
+	}
+	return h / 6, s, max
+}
+
+// HSVToRGB undoes RGBToHSV.
+func HSVToRGB(h float64, s float64, v float64) (float64, float64, float64) {
+	h = (h - math.Floor(h)) * 6
+	chroma := v * s
+	x := chroma * (1 - math.Abs(math.Mod(h, 2) - 1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g, b = chroma, x, 0
+	case 1:
+		r, g, b = x, chroma, 0
+	case 2:
+		r, g, b = 0, chroma, x
+	case 3:
+		r, g, b = 0, x, chroma
+	case 4:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	m := v - chroma
+	return r + m, g + m, b + m
+}
+
+// RGBToYCbCr converts a sRGB color to the YCbCr of JPEG, without the rounding to 8 bits of color.RGBToYCbCr.
+func RGBToYCbCr(r float64, g float64, b float64) (float64, float64, float64) {
+	return 0.299 * r + 0.587 * g + 0.114 * b,
+		-0.168736 * r - 0.331264 * g + 0.5 * b,
+		0.5 * r - 0.418688 * g - 0.081312 * b
+}
+
+// YCbCrToRGB undoes RGBToYCbCr.
+func YCbCrToRGB(y float64, cb float64, cr float64) (float64, float64, float64) {
+	return y + 1.402 * cr, y - 0.344136 * cb - 0.714136 * cr, y + 1.772 * cb
+}
+
+// ConvertColor converts the three channels of a color from a space to another.
+func ConvertColor(c [3]float64, from ColorSpace, to ColorSpace) [3]float64 {
+	if from == to {
+		return c
+	}
+
+	// Lab and linear RGB go through linear RGB, the others through sRGB.
+	var r, g, b float64
+	switch from {
+	case LinearRGB:
+		if to == Lab {
+			r, g, b = LinearRGBToLab(c[0], c[1], c[2])
+			return [3]float64{r, g, b}
+		}
+		r, g, b = LinearToSRGB(c[0]), LinearToSRGB(c[1]), LinearToSRGB(c[2])
+	case Lab:
+		r, g, b = LabToLinearRGB(c[0], c[1], c[2])
+		if to == LinearRGB {
+			return [3]float64{r, g, b}
+		}
+		r, g, b = LinearToSRGB(r), LinearToSRGB(g), LinearToSRGB(b)
+	case HSV:
+		r, g, b = HSVToRGB(c[0], c[1], c[2])
+	case YCbCr:
+		r, g, b = YCbCrToRGB(c[0], c[1], c[2])
+	default:
+		r, g, b = c[0], c[1], c[2]
+	}
+
+	switch to {
+	case LinearRGB:
+		r, g, b = SRGBToLinear(r), SRGBToLinear(g), SRGBToLinear(b)
+	case Lab:
+		r, g, b = LinearRGBToLab(SRGBToLinear(r), SRGBToLinear(g), SRGBToLinear(b))
+	case HSV:
+		r, g, b = RGBToHSV(r, g, b)
+	case YCbCr:
+		r, g, b = RGBToYCbCr(r, g, b)
+	}
+	return [3]float64{r, g, b}
+}
+
+// ColorPlanes returns the three channels of the image in the color space. The colors are premultiplied by alpha like
+// in GetGrayImage, so the transparent pixels are black.
+func ColorPlanes(img image.Image, space ColorSpace) []*Plane {
+	return PlanesFromImage(img, 3, func(r, g, b, _ uint32, out []float64) {
+		var c [3]float64
+		switch space {
+		case LinearRGB:
+			c = [3]float64{linear16(r), linear16(g), linear16(b)}
+		case Lab:
+			c[0], c[1], c[2] = LinearRGBToLab(linear16(r), linear16(g), linear16(b))
+		default:
+			c = ConvertColor([3]float64{float64(r) / 65535, float64(g) / 65535, float64(b) / 65535}, RGB, space)
+		}
+		copy(out, c[:])
+	})
+}
+
+// ConvertPlanes converts the three channel planes of a color space to new planes of another space.
+func ConvertPlanes(planes []*Plane, from ColorSpace, to ColorSpace) []*Plane {
+	ret := make([]*Plane, 3)
+	for i := range ret {
+		ret[i] = NewPlane(planes[0].Width, planes[0].Height)
+	}
+	for y := 0; y < planes[0].Height; y++ {
+		for x := 0; x < planes[0].Width; x++ {
+			c := ConvertColor([3]float64{planes[0].At(x, y), planes[1].At(x, y), planes[2].At(x, y)}, from, to)
+			for i := range ret {
+				ret[i].Set(x, y, c[i])
+			}
+		}
+	}
+	return ret
+}
+
+// ImageFromPlanes builds an opaque 16 bit image from the three channel planes of a color space, the colors out of
+// sRGB are clamped.
+func ImageFromPlanes(planes []*Plane, space ColorSpace) *image.RGBA64 {
+	img := image.NewRGBA64(image.Rect(0, 0, planes[0].Width, planes[0].Height))
+	to16 := func(v float64) uint16 {
+		return uint16(math.Round(math.Max(0, math.Min(1, v)) * 65535))
+	}
+	for y := 0; y < planes[0].Height; y++ {
+		for x := 0; x < planes[0].Width; x++ {
+			c := ConvertColor([3]float64{planes[0].At(x, y), planes[1].At(x, y), planes[2].At(x, y)}, space, RGB)
+			img.SetRGBA64(x, y, color.RGBA64{R: to16(c[0]), G: to16(c[1]), B: to16(c[2]), A: 0xffff})
+		}
+	}
+	return img
+}
@@ -0,0 +1,205 @@
+package meta
+
+import (
+	"fmt"
+	"math"
+)
+
+// Border is the way the convolutions read the values out of the plane.
+type Border int
+
+const (
+	// BorderClamp repeats the value of the nearest border pixel: aaa|abcd|ddd.
+	BorderClamp Border = iota
+	// BorderReflect mirrors the plane around its border pixels, which are not repeated: dcb|abcd|cba.
+	BorderReflect
+	// BorderWrap continues with the other side of the plane: bcd|abcd|abc.
+	BorderWrap
+	// BorderZero reads zeros out of the plane.
+	BorderZero
+)
+
+var borderNames = []string{"clamp", "reflect", "wrap", "zero"}
+
+func (b Border) String() string {
+	if b < 0 || int(b) >= len(borderNames) {
+		return fmt.Sprintf("Border(%d)", int(b))
+	}
+	return borderNames[b]
+}
+
+// coordinate maps the coordinate i of a line of n values inside the line, false when the value is a zero of
+// BorderZero.
+func (b Border) coordinate(i int, n int) (int, bool) {
+	if i >= 0 && i < n {
+		return i, true
+	}
+	switch b {
+	case BorderClamp:
+		return ClampInt(i, 0, n - 1), true
+	case BorderReflect:
+		if n == 1 {
+			return 0, true
+		}
+		period := 2 * (n - 1)
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		return i, true
+	case BorderWrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i, true
+	}
+	return 0, false
+}
+
+// padPlane returns a copy of the plane with rx columns on the left and the right and ry lines above and below,
+// filled as told by the border.
+func padPlane(p *Plane, rx int, ry int, border Border) *Plane {
+	ret := NewPlane(p.Width + 2 * rx, p.Height + 2 * ry)
+	for y := 0; y < ret.Height; y++ {
+		srcY, ok := border.coordinate(y - ry, p.Height)
+		if !ok {
+			continue
+		}
+		src := p.Row(srcY)
+		row := ret.Row(y)
+		copy(row[rx:], src)
+		for x := 0; x < rx; x++ {
+			if srcX, ok := border.coordinate(x - rx, p.Width); ok {
+				row[x] = src[srcX]
+			}
+			if srcX, ok := border.coordinate(p.Width + x, p.Width); ok {
+				row[rx + p.Width + x] = src[srcX]
+			}
+		}
+	}
+	return ret
+}
+
+// Convolve applies the kernel, indexed [x][y] with odd sizes, centered on every pixel of the plane. The kernels which
+// are the product of a column and a line, like the ones of Sobel, go through ConvolveSeparable.
+func Convolve(gray *Plane, kernel [][]float64, border Border) *Plane {
+	if kx, ky, ok := SeparateKernel(kernel); ok {
+		return ConvolveSeparable(gray, kx, ky, border)
+	}
+
+	rx, ry := len(kernel) / 2, len(kernel[0]) / 2
+	padded := padPlane(gray, rx, ry, border)
+	ret := NewPlane(gray.Width, gray.Height)
+
+	for y := 0; y < ret.Height; y++ {
+		row := ret.Row(y)
+		for j := 0; j <= 2 * ry; j++ {
+			src := padded.Row(y + j)
+			for i := 0; i <= 2 * rx; i++ {
+				weight := kernel[i][j]
+				if weight == 0 {
+					continue
+				}
+				for x, val := range src[i:i + ret.Width] {
+					row[x] += weight * val
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// ConvolveSeparable applies the kernel whose value [i][j] is kx[i]*ky[j], one direction after the other, so a
+// kernel of n x n values costs 2n operations by pixel instead of n*n.
+func ConvolveSeparable(gray *Plane, kx []float64, ky []float64, border Border) *Plane {
+	rx, ry := len(kx) / 2, len(ky) / 2
+	padded := padPlane(gray, rx, ry, border)
+
+	// The lines first, on all the lines of the padding which the columns need after.
+	lines := NewPlane(gray.Width, padded.Height)
+	for y := 0; y < lines.Height; y++ {
+		src := padded.Row(y)
+		row := lines.Row(y)
+		for i, weight := range kx {
+			if weight == 0 {
+				continue
+			}
+			for x, val := range src[i:i + gray.Width] {
+				row[x] += weight * val
+			}
+		}
+	}
+
+	ret := NewPlane(gray.Width, gray.Height)
+	for y := 0; y < ret.Height; y++ {
+		row := ret.Row(y)
+		for j, weight := range ky {
+			if weight == 0 {
+				continue
+			}
+			for x, val := range lines.Row(y + j) {
+				row[x] += weight * val
+			}
+		}
+	}
+	return ret
+}
+
+// SeparateKernel splits the kernel, indexed [x][y], in a column kx and a line ky whose product kx[i]*ky[j] is the
+// kernel, when there are such values.
+func SeparateKernel(kernel [][]float64) ([]float64, []float64, bool) {
+	// The largest value gives the line and the column which are the most precise.
+	bestI, bestJ := 0, 0
+	for i := range kernel {
+		for j := range kernel[i] {
+			if math.Abs(kernel[i][j]) > math.Abs(kernel[bestI][bestJ]) {
+				bestI, bestJ = i, j
+			}
+		}
+	}
+	pivot := kernel[bestI][bestJ]
+	if pivot == 0 {
+		return nil, nil, false
+	}
+
+	kx := make([]float64, len(kernel))
+	for i := range kernel {
+		kx[i] = kernel[i][bestJ] / pivot
+	}
+	ky := append([]float64(nil), kernel[bestI]...)
+
+	for i := range kernel {
+		for j := range kernel[i] {
+			if math.Abs(kx[i] * ky[j] - kernel[i][j]) > 1e-12 * math.Abs(pivot) {
+				return nil, nil, false
+			}
+		}
+	}
+	return kx, ky, true
+}
+
+// GaussianKernel returns the normalized one dimensional gaussian covering 3 sigma on each side.
+func GaussianKernel(sigma float64) []float64 {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2 * radius + 1)
+	sum := float64(0)
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+// GaussianBlur smooths the plane with a gaussian of the given standard deviation, in pixels.
+func GaussianBlur(gray *Plane, sigma float64, border Border) *Plane {
+	kernel := GaussianKernel(sigma)
+	return ConvolveSeparable(gray, kernel, kernel, border)
+}
@@ -0,0 +1,407 @@
+package meta
+
+import (
+	"github.com/pkg/errors"
+	"image"
+	"math"
+	"sort"
+)
+
+// EnergyFunc computes the energy of every pixel of an image.
+type EnergyFunc interface {
+	Energy(img image.Image) *Plane
+}
+
+// LocalEnergyFunc is an EnergyFunc whose value on a pixel only depends on the pixels at most Radius() away, so it
+// can be computed again only around the pixels which changed.
+type LocalEnergyFunc interface {
+	EnergyFunc
+	Radius() int
+}
+
+// EnergyFunction adapts an ordinary function to the EnergyFunc interface.
+type EnergyFunction func(img image.Image) *Plane
+
+func (f EnergyFunction) Energy(img image.Image) *Plane {
+	return f(img)
+}
+
+var (
+	SobelX = [][]float64{
+		{-1, 0, 1},
+		{-2, 0, 2},
+		{-1, 0, 1},
+	}
+	SobelY = [][]float64{
+		{-1, -2, -1},
+		{0, 0, 0},
+		{1, 2, 1},
+	}
+	ScharrX = [][]float64{
+		{-3, 0, 3},
+		{-10, 0, 10},
+		{-3, 0, 3},
+	}
+	ScharrY = [][]float64{
+		{-3, -10, -3},
+		{0, 0, 0},
+		{3, 10, 3},
+	}
+	PrewittX = [][]float64{
+		{-1, 0, 1},
+		{-1, 0, 1},
+		{-1, 0, 1},
+	}
+	PrewittY = [][]float64{
+		{-1, -1, -1},
+		{0, 0, 0},
+		{1, 1, 1},
+	}
+)
+
+// GradientEnergy is the magnitude of the gradient of the gray image given by a pair of 3x3 kernels, the border
+// pixels are clamped so they get a real gradient.
+type GradientEnergy struct {
+	KernelX [][]float64
+	KernelY [][]float64
+	// L1 sums the absolute values of the two derivatives instead of taking the euclidean norm.
+	L1 bool
+}
+
+func (g GradientEnergy) Energy(img image.Image) *Plane {
+	return GradientFilter(GetGrayImage(img), g.KernelX, g.KernelY, g.L1)
+}
+
+func (g GradientEnergy) Radius() int {
+	return 1
+}
+
+// ChannelCombine is the way ColorGradientEnergy merges the gradients of the color channels.
+type ChannelCombine int
+
+const (
+	// ChannelSum adds the gradient magnitudes of the channels.
+	ChannelSum ChannelCombine = iota
+	// ChannelMax keeps the strongest gradient magnitude of the channels.
+	ChannelMax
+	// ChannelNorm is the euclidean norm of the gradients of all the channels, the length of the color difference.
+	ChannelNorm
+)
+
+// ColorGradientEnergy is the gradient of the color channels instead of the luminance, so the boundaries between colors
+// of the same luminance, like red text on green, still have energy.
+type ColorGradientEnergy struct {
+	KernelX [][]float64
+	KernelY [][]float64
+	Combine ChannelCombine
+	// Space is the color space of the channels, the magnitude is in its units. The hue of HSV is not circular for the
+	// gradient.
+	Space ColorSpace
+}
+
+func (c ColorGradientEnergy) Energy(img image.Image) *Plane {
+	channels := ColorPlanes(img, c.Space)
+
+	magnitude := NewPlane(img.Bounds().Dx(), img.Bounds().Dy())
+	for _, channel := range channels {
+		gradientX := Convolve(channel, c.KernelX, BorderClamp)
+		gradientY := Convolve(channel, c.KernelY, BorderClamp)
+		for i, sx := range gradientX.Pix {
+			sy := gradientY.Pix[i]
+			switch c.Combine {
+			case ChannelSum:
+				magnitude.Pix[i] += math.Sqrt(sx * sx + sy * sy)
+			case ChannelMax:
+				magnitude.Pix[i] = math.Max(magnitude.Pix[i], math.Sqrt(sx * sx + sy * sy))
+			case ChannelNorm:
+				magnitude.Pix[i] += sx * sx + sy * sy
+			}
+		}
+	}
+	if c.Combine == ChannelNorm {
+		for i, val := range magnitude.Pix {
+			magnitude.Pix[i] = math.Sqrt(val)
+		}
+	}
+	return magnitude
+}
+
+func (c ColorGradientEnergy) Radius() int {
+	return 1
+}
+
+// LaplacianOfGaussianEnergy is the absolute response of the gray image to a laplacian of gaussian kernel.
+type LaplacianOfGaussianEnergy struct {
+	Sigma float64
+}
+
+func (l LaplacianOfGaussianEnergy) Energy(img image.Image) *Plane {
+	magnitude := Convolve(GetGrayImage(img), LaplacianOfGaussianKernel(l.Sigma), BorderClamp)
+	for i := range magnitude.Pix {
+		magnitude.Pix[i] = math.Abs(magnitude.Pix[i])
+	}
+	return magnitude
+}
+
+func (l LaplacianOfGaussianEnergy) Radius() int {
+	return len(LaplacianOfGaussianKernel(l.Sigma)) / 2
+}
+
+// EntropyEnergy is the entropy of the gray levels histogram in the square window around every pixel.
+type EntropyEnergy struct {
+	// WindowRadius is the radius of the window, which has 2*WindowRadius+1 pixels in length.
+	WindowRadius int
+	// Bins is the number of gray levels of the histogram.
+	Bins int
+}
+
+func (e EntropyEnergy) Energy(img image.Image) *Plane {
+	gray := GetGrayImage(img)
+	magnitude := NewPlane(gray.Width, gray.Height)
+
+	quantized := make([]int, len(gray.Pix))
+	for i, val := range gray.Pix {
+		quantized[i] = int(val * float64(e.Bins) / 65536)
+		if quantized[i] >= e.Bins {
+			quantized[i] = e.Bins - 1
+		}
+	}
+
+	histogram := make([]int, e.Bins)
+	for x := 0; x < gray.Width; x++ {
+		for i := range histogram {
+			histogram[i] = 0
+		}
+		count := 0
+		// The window slides down, one line enters and one line leaves at every step.
+		updateLine := func(y int, val int) {
+			if y < 0 || y >= gray.Height {
+				return
+			}
+			for i := x - e.WindowRadius; i <= x + e.WindowRadius; i++ {
+				if i < 0 || i >= gray.Width {
+					continue
+				}
+				histogram[quantized[y * gray.Stride + i]] += val
+				count += val
+			}
+		}
+		for y := -e.WindowRadius; y < e.WindowRadius; y++ {
+			updateLine(y, 1)
+		}
+		for y := 0; y < gray.Height; y++ {
+			updateLine(y + e.WindowRadius, 1)
+			updateLine(y - e.WindowRadius - 1, -1)
+
+			entropy := float64(0)
+			for _, h := range histogram {
+				if h == 0 {
+					continue
+				}
+				p := float64(h) / float64(count)
+				entropy -= p * math.Log2(p)
+			}
+			magnitude.Set(x, y, entropy)
+		}
+	}
+	return magnitude
+}
+
+func (e EntropyEnergy) Radius() int {
+	return e.WindowRadius
+}
+
+// HOGEnergy is the L1 sobel magnitude divided by the maximum bin of the histogram of oriented gradients in the
+// window around every pixel, as proposed in the seam carving paper.
+type HOGEnergy struct {
+	// WindowRadius is the radius of the window, which has 2*WindowRadius+1 pixels in length.
+	WindowRadius int
+	// Bins is the number of orientations of the histogram.
+	Bins int
+}
+
+func (h HOGEnergy) Energy(img image.Image) *Plane {
+	gray := GetGrayImage(img)
+	width := gray.Width
+	height := gray.Height
+
+	// One summed area table for each orientation, with an extra line and column of zeros.
+	sums := make([]*Plane, h.Bins)
+	for bin := range sums {
+		sums[bin] = NewPlane(width + 1, height + 1)
+	}
+
+	gradientX := Convolve(gray, SobelX, BorderClamp)
+	gradientY := Convolve(gray, SobelY, BorderClamp)
+	magnitude := NewPlane(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sx := gradientX.At(x, y)
+			sy := gradientY.At(x, y)
+			magnitude.Set(x, y, math.Abs(sx) + math.Abs(sy))
+
+			// Orientations are unsigned, in [0, pi).
+			angle := math.Atan2(sy, sx)
+			if angle < 0 {
+				angle += math.Pi
+			}
+			bin := int(angle / math.Pi * float64(h.Bins))
+			if bin >= h.Bins {
+				bin = h.Bins - 1
+			}
+			sums[bin].Set(x + 1, y + 1, math.Sqrt(sx * sx + sy * sy))
+		}
+	}
+
+	for _, sum := range sums {
+		for x := 1; x <= width; x++ {
+			for y := 1; y <= height; y++ {
+				sum.Set(x, y, sum.At(x, y) + (sum.At(x - 1, y) + sum.At(x, y - 1) - sum.At(x - 1, y - 1)))
+			}
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			left, right := ClampInt(x - h.WindowRadius, 0, width - 1), ClampInt(x + h.WindowRadius, 0, width - 1) + 1
+			up, down := ClampInt(y - h.WindowRadius, 0, height - 1), ClampInt(y + h.WindowRadius, 0, height - 1) + 1
+
+			maxBin := float64(0)
+			for _, sum := range sums {
+				val := sum.At(right, down) - sum.At(left, down) - sum.At(right, up) + sum.At(left, up)
+				if val > maxBin {
+					maxBin = val
+				}
+			}
+
+			if maxBin > 0 {
+				magnitude.Set(x, y, magnitude.At(x, y) / maxBin)
+			} else {
+				magnitude.Set(x, y, 0)
+			}
+		}
+	}
+	return magnitude
+}
+
+// The window is around the sobel gradients, which also need their neighbours.
+func (h HOGEnergy) Radius() int {
+	return h.WindowRadius + 1
+}
+
+type zeroEnergy struct{}
+
+func (zeroEnergy) Energy(img image.Image) *Plane {
+	return NewPlane(img.Bounds().Dx(), img.Bounds().Dy())
+}
+
+func (zeroEnergy) Radius() int {
+	return 0
+}
+
+// ZeroEnergy gives the same energy to all the pixels.
+var ZeroEnergy EnergyFunc = zeroEnergy{}
+
+// TransparentAsZero wraps an energy so the fully transparent pixels have no energy, the seams then prefer the empty
+// space around the content. The wrapper is local when the energy is.
+func TransparentAsZero(energy EnergyFunc) EnergyFunc {
+	if local, ok := energy.(LocalEnergyFunc); ok {
+		return localTransparentEnergy{transparentEnergy{energy}, local.Radius()}
+	}
+	return transparentEnergy{energy}
+}
+
+type transparentEnergy struct {
+	inner EnergyFunc
+}
+
+func (t transparentEnergy) Energy(img image.Image) *Plane {
+	magnitude := t.inner.Energy(img)
+	alpha := GetAlphaImage(img)
+	for i, a := range alpha.Pix {
+		if a == 0 {
+			magnitude.Pix[i] = 0
+		}
+	}
+	return magnitude
+}
+
+type localTransparentEnergy struct {
+	transparentEnergy
+	radius int
+}
+
+func (l localTransparentEnergy) Radius() int {
+	return l.radius
+}
+
+var energies = map[string]EnergyFunc{
+	"sobel":     GradientEnergy{KernelX: SobelX, KernelY: SobelY},
+	"sobel-l1":  GradientEnergy{KernelX: SobelX, KernelY: SobelY, L1: true},
+	"scharr":    GradientEnergy{KernelX: ScharrX, KernelY: ScharrY},
+	"prewitt":   GradientEnergy{KernelX: PrewittX, KernelY: PrewittY},
+	"color-sum": ColorGradientEnergy{KernelX: SobelX, KernelY: SobelY, Combine: ChannelSum},
+	"color-max": ColorGradientEnergy{KernelX: SobelX, KernelY: SobelY, Combine: ChannelMax},
+	"lab":       ColorGradientEnergy{KernelX: SobelX, KernelY: SobelY, Combine: ChannelNorm, Space: Lab},
+	"log":       LaplacianOfGaussianEnergy{Sigma: 1.4},
+	"entropy":   EntropyEnergy{WindowRadius: 4, Bins: 16},
+	"hog":       HOGEnergy{WindowRadius: 5, Bins: 8},
+	"zero":      ZeroEnergy,
+}
+
+// EnergyByName returns one of the built-in energies with its default parameters.
+func EnergyByName(name string) (EnergyFunc, error) {
+	energy, ok := energies[name]
+	if !ok {
+		return nil, errors.Errorf("unknown energy '%v', expected one of %v", name, EnergyNames())
+	}
+	return energy, nil
+}
+
+// EnergyNames returns the sorted names accepted by EnergyByName.
+func EnergyNames() []string {
+	var names []string
+	for name := range energies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LaplacianOfGaussianKernel returns a zero sum kernel, indexed [x][y], of the laplacian of gaussian covering 3 sigma
+// on each side.
+func LaplacianOfGaussianKernel(sigma float64) [][]float64 {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([][]float64, 2 * radius + 1)
+	for i := range kernel {
+		kernel[i] = make([]float64, 2 * radius + 1)
+	}
+
+	sum := float64(0)
+	for i := -radius; i <= radius; i++ {
+		for j := -radius; j <= radius; j++ {
+			r2 := float64(i * i + j * j) / (2 * sigma * sigma)
+			kernel[i + radius][j + radius] = -1 / (math.Pi * sigma * sigma * sigma * sigma) * (1 - r2) * math.Exp(-r2)
+			sum += kernel[i + radius][j + radius]
+		}
+	}
+
+	mean := sum / float64(len(kernel) * len(kernel))
+	for i := range kernel {
+		for j := range kernel[i] {
+			kernel[i][j] -= mean
+		}
+	}
+	return kernel
+}
+
+// ClampInt brings the value in [min, max].
+func ClampInt(val int, min int, max int) int {
+	if val < min {
+		return min
+	}
+	if val > max {
+		return max
+	}
+	return val
+}
@@ -0,0 +1,106 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const orientationTag = 0x0112
+
+// ExifOrientation returns the orientation tag, from 1 to 8, of the EXIF segment of a JPEG file. It returns 1, the
+// normal orientation, when the file has no such tag.
+func ExifOrientation(data []byte) int {
+	if !bytes.HasPrefix(data, []byte("\xff\xd8")) {
+		return 1
+	}
+
+	// The segments start after the SOI marker, the EXIF one is an APP1 segment before the image data.
+	for pos := 2; pos + 4 <= len(data); {
+		if data[pos] != 0xff {
+			return 1
+		}
+		marker := data[pos + 1]
+		if marker == 0xff {
+			// Fill byte before a marker.
+			pos++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos + 2:]))
+		if length < 2 || pos + 2 + length > len(data) {
+			return 1
+		}
+		segment := data[pos + 4:pos + 2 + length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag of the first IFD of the TIFF structure inside the EXIF segment.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd + 2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i * 12
+		if entry + 12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+		// A SHORT value is stored in the first bytes of the value field.
+		orientation := int(order.Uint16(tiff[entry + 8:]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+	return 1
+}
+
+// ApplyOrientation returns the image as it should be displayed for the EXIF orientation, the image itself for 1 or
+// an unknown value.
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	switch orientation {
+	case 2:
+		return remapImage(img, false, func(x, y int) (int, int) { return w - 1 - x, y })
+	case 3:
+		return Rotate180(img)
+	case 4:
+		return remapImage(img, false, func(x, y int) (int, int) { return x, h - 1 - y })
+	case 5:
+		return Transpose(img)
+	case 6:
+		return Rotate90(img)
+	case 7:
+		return remapImage(img, true, func(x, y int) (int, int) { return w - 1 - y, h - 1 - x })
+	case 8:
+		return Rotate270(img)
+	}
+	return img
+}
@@ -0,0 +1,140 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifTIFF returns the TIFF structure of an EXIF segment whose first IFD only has the orientation tag.
+func exifTIFF(order binary.ByteOrder, orientation int) []byte {
+	tiff := make([]byte, 8 + 2 + 12 + 4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	// One SHORT value, stored in the first bytes of the value field.
+	order.PutUint16(tiff[10:], orientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+	return tiff
+}
+
+// withExif inserts an APP1 EXIF segment with the TIFF structure right after the SOI marker of the JPEG file.
+func withExif(file []byte, tiff []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2 + len(payload)))
+	segment = append(segment, payload...)
+
+	ret := append([]byte{}, file[:2]...)
+	ret = append(ret, segment...)
+	return append(ret, file[2:]...)
+}
+
+// markedJPEG encodes a 24x16 black image whose top left 8x8 block is white, so the blocks of the encoder keep it sharp.
+func markedJPEG(t *testing.T) []byte {
+	img := image.NewGray(image.Rect(0, 0, 24, 16))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// whiteCorner returns which corner of the image is white: 0 top left, 1 top right, 2 bottom left, 3 bottom right,
+// -1 if not exactly one of them.
+func whiteCorner(img image.Image) int {
+	b := img.Bounds()
+	corners := []image.Point{{b.Min.X, b.Min.Y}, {b.Max.X - 1, b.Min.Y}, {b.Min.X, b.Max.Y - 1}, {b.Max.X - 1, b.Max.Y - 1}}
+	found := -1
+	for i, p := range corners {
+		r, _, _, _ := img.At(p.X, p.Y).RGBA()
+		if r > 0x8000 {
+			if found != -1 {
+				return -1
+			}
+			found = i
+		}
+	}
+	return found
+}
+
+func TestExifOrientation(t *testing.T) {
+	file := markedJPEG(t)
+	// The corner where the stored top left pixel is displayed for the orientations 1 to 8.
+	corners := []int{0, 1, 3, 2, 0, 1, 3, 2}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for orientation := 1; orientation <= 8; orientation++ {
+			data := withExif(file, exifTIFF(order, orientation))
+			if got := ExifOrientation(data); got != orientation {
+				t.Errorf("%v, orientation %v: read %v", order, orientation, got)
+			}
+
+			img, err := DecodeImage(bytes.NewReader(data), DecodeOptions{})
+			if err != nil {
+				t.Fatalf("%v, orientation %v: %v", order, orientation, err)
+			}
+			size := image.Pt(24, 16)
+			if orientation >= 5 {
+				size = image.Pt(16, 24)
+			}
+			if img.Bounds().Size() != size {
+				t.Errorf("%v, orientation %v: size %v, expected %v", order, orientation, img.Bounds().Size(), size)
+			}
+			if corner := whiteCorner(img); corner != corners[orientation - 1] {
+				t.Errorf("%v, orientation %v: white corner %v, expected %v", order, orientation, corner, corners[orientation - 1])
+			}
+
+			kept, err := DecodeImage(bytes.NewReader(data), DecodeOptions{KeepOrientation: true})
+			if err != nil {
+				t.Fatalf("%v, orientation %v: %v", order, orientation, err)
+			}
+			if kept.Bounds().Size() != image.Pt(24, 16) || whiteCorner(kept) != 0 {
+				t.Errorf("%v, orientation %v: KeepOrientation changed the image", order, orientation)
+			}
+		}
+	}
+}
+
+func TestExifOrientationInvalid(t *testing.T) {
+	file := markedJPEG(t)
+
+	badOffset := exifTIFF(binary.LittleEndian, 6)
+	binary.LittleEndian.PutUint32(badOffset[4:], 1000)
+	if got := ExifOrientation(withExif(file, badOffset)); got != 1 {
+		t.Errorf("IFD offset out of the segment: read %v", got)
+	}
+
+	truncated := exifTIFF(binary.BigEndian, 6)[:16]
+	if got := ExifOrientation(withExif(file, truncated)); got != 1 {
+		t.Errorf("IFD entry cut: read %v", got)
+	}
+
+	outOfRange := exifTIFF(binary.BigEndian, 9)
+	if got := ExifOrientation(withExif(file, outOfRange)); got != 1 {
+		t.Errorf("orientation 9: read %v", got)
+	}
+
+	// Every prefix of the file, which cuts the segments anywhere, reads 1 or the whole tag without panicking.
+	data := withExif(file, exifTIFF(binary.LittleEndian, 6))
+	for n := range data {
+		if got := ExifOrientation(data[:n]); got != 1 && got != 6 {
+			t.Errorf("prefix of %v bytes: read %v", n, got)
+		}
+	}
+}
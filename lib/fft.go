@@ -0,0 +1,65 @@
+package meta
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// fft computes in place the discrete Fourier transform of values, whose length is a power of two. The inverse
+// transform is divided by the length, so it undoes the forward one.
+func fft(values []complex128, inverse bool) {
+	n := len(values)
+	if n < 2 {
+		return
+	}
+
+	// The values are put in the bit reversed order of their index, then merged by butterflies of growing size.
+	shift := 64 - uint(bits.TrailingZeros(uint(n)))
+	for i := range values {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size *= 2 {
+		step := cmplx.Rect(1, sign * 2 * math.Pi / float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size / 2; k++ {
+				even, odd := values[start + k], w * values[start + k + size / 2]
+				values[start + k] = even + odd
+				values[start + k + size / 2] = even - odd
+				w *= step
+			}
+		}
+	}
+
+	if inverse {
+		for i := range values {
+			values[i] /= complex(float64(n), 0)
+		}
+	}
+}
+
+// fft2 is fft on the lines then on the columns of a w x h grid stored line after line, both powers of two.
+func fft2(values []complex128, w int, h int, inverse bool) {
+	for y := 0; y < h; y++ {
+		fft(values[y * w:(y + 1) * w], inverse)
+	}
+	column := make([]complex128, h)
+	for x := 0; x < w; x++ {
+		for y := range column {
+			column[y] = values[y * w + x]
+		}
+		fft(column, inverse)
+		for y, val := range column {
+			values[y * w + x] = val
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package meta
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/pkg/errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	// The decoders register themselves for image.Decode, webp has no encoder.
+	_ "golang.org/x/image/webp"
+)
+
+// SupportedFormats are the formats of the images which can be decoded.
+var SupportedFormats = []string{"jpeg", "png", "gif", "bmp", "tiff", "webp"}
+
+// SniffFormat returns the format of an image from its first bytes, or "" if it is none of the SupportedFormats.
+func SniffFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("\xff\xd8")):
+		return "jpeg"
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return "gif"
+	case bytes.HasPrefix(header, []byte("BM")):
+		return "bmp"
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return "tiff"
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return "webp"
+	}
+	return ""
+}
+
+// DecodeOptions are the parameters of the decoders, the zero value follows the EXIF orientation.
+type DecodeOptions struct {
+	// KeepOrientation keeps the JPEG images as stored instead of turning them as told by the orientation of their
+	// EXIF segment, like the photos of the phones which are stored sideways.
+	KeepOrientation bool
+}
+
+// DecodeImage is GetImageFromReader with the options of the decoders.
+func DecodeImage(reader io.Reader, opts DecodeOptions) (image.Image, error) {
+	// The whole content is kept for reading the EXIF segment after decoding.
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the image")
+	}
+
+	format := SniffFormat(data)
+	if format == "" {
+		return nil, errors.Errorf("unknown image format, the supported formats are %v", strings.Join(SupportedFormats, ", "))
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %v content", format)
+	}
+
+	if !opts.KeepOrientation && format == "jpeg" {
+		img = ApplyOrientation(img, ExifOrientation(data))
+	}
+	return img, nil
+}
+
+// EncodeFormats are the formats in which the images can be written.
+var EncodeFormats = []string{"jpeg", "png", "gif", "bmp", "tiff"}
+
+var extensionFormats = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".bmp":  "bmp",
+	".tif":  "tiff",
+	".tiff": "tiff",
+}
+
+var pngCompressions = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// EncodeOptions are the parameters of the encoders.
+type EncodeOptions struct {
+	// Format is one of the EncodeFormats, found from the extension of the path when empty.
+	Format string
+	// JPEGQuality is in [1, 100], jpeg.DefaultQuality when 0.
+	JPEGQuality int
+	// PNGCompression is the compression level of the png encoder, the zero value is the default one.
+	PNGCompression png.CompressionLevel
+	// NoClobber makes WriteImage fail instead of replacing an existing file.
+	NoClobber bool
+}
+
+// PNGCompressionByName parses the cli names of the png compression levels: default, none, fast and best.
+func PNGCompressionByName(name string) (png.CompressionLevel, error) {
+	level, ok := pngCompressions[name]
+	if !ok {
+		return 0, errors.Errorf("unknown png compression '%v', expected default, none, fast or best", name)
+	}
+	return level, nil
+}
+
+// FormatFromPath returns the format matching the extension of the path.
+func FormatFromPath(path string) (string, error) {
+	format, ok := extensionFormats[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", errors.Errorf("could not find the format of '%v' from its extension, the supported formats are %v",
+			path, strings.Join(EncodeFormats, ", "))
+	}
+	return format, nil
+}
+
+// EncodeImage writes the image in the given format.
+func EncodeImage(writer io.Writer, img image.Image, format string, opts EncodeOptions) error {
+	switch format {
+	case "jpeg":
+		quality := opts.JPEGQuality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		if quality < 1 || quality > 100 {
+			return errors.Errorf("the jpeg quality %v is not in [1, 100]", quality)
+		}
+		return jpeg.Encode(writer, img, &jpeg.Options{Quality: quality})
+	case "png":
+		encoder := png.Encoder{CompressionLevel: opts.PNGCompression}
+		return encoder.Encode(writer, img)
+	case "gif":
+		return gif.Encode(writer, img, nil)
+	case "bmp":
+		return bmp.Encode(writer, img)
+	case "tiff":
+		return tiff.Encode(writer, img, &tiff.Options{Compression: tiff.Deflate})
+	}
+	return errors.Errorf("unknown output format '%v', expected one of %v", format, strings.Join(EncodeFormats, ", "))
+}
+
+// WriteImage saves the image at the path, in the format of the options or else the one of the extension of the path.
+// The path StdStream writes on the standard output, in png when there is no format in the options.
+func WriteImage(path string, img image.Image, opts EncodeOptions) error {
+	format := opts.Format
+	if format == "" && path == StdStream {
+		format = "png"
+	}
+	if format == "" {
+		var err error
+		format, err = FormatFromPath(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := CreateOutput(path, opts.NoClobber)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := bufio.NewWriter(out)
+	if err := EncodeImage(writer, img, format, opts); err != nil {
+		return errors.Wrapf(err, "could not encode the image in %v format", format)
+	}
+	if err := writer.Flush(); err != nil {
+		return errors.Wrapf(err, "could not write the image at '%v'", path)
+	}
+	return out.Commit()
+}
@@ -4,7 +4,8 @@ import (
 	"github.com/pkg/errors"
 	"image"
 	"image/color"
-	"image/jpeg"
+	"image/draw"
+	"io"
 
 	"math"
 	"os"
@@ -15,117 +16,168 @@ type Point struct {
 	Y 	int
 }
 
+// Set once the image on the standard input was decoded, it can not be read a second time.
+var stdinRead bool
+
+// GetImageFromPath decodes an image in any of the SupportedFormats, the format is found from the content.
+// The path StdStream reads the standard input.
 func GetImageFromPath(path string) (image.Image, error) {
+	return DecodeImageFromPath(path, DecodeOptions{})
+}
+
+// DecodeImageFromPath is GetImageFromPath with the options of the decoders.
+func DecodeImageFromPath(path string, opts DecodeOptions) (image.Image, error) {
+	if path == StdStream {
+		if stdinRead {
+			return nil, errors.New("the standard input was already read for another image")
+		}
+		stdinRead = true
+
+		img, err := DecodeImage(os.Stdin, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decode the standard input")
+		}
+		return img, nil
+	}
+
 	imgFile, err := os.Open(path)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not open file '%v'", path)
 	}
 	defer imgFile.Close()
 
-	img, _, err := image.Decode(imgFile)
+	img, err := DecodeImage(imgFile, opts)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not decode file '%v'", path)
 	}
 	return img, nil
 }
 
-func GetGrayImage(img image.RGBA) [][]float64 {
-	bounds := img.Bounds()
-
-	grayScale := make([][]float64, bounds.Dx())
+// GetImageFromReader decodes an image in any of the SupportedFormats from the reader.
+func GetImageFromReader(reader io.Reader) (image.Image, error) {
+	return DecodeImage(reader, DecodeOptions{})
+}
 
-	for i := range grayScale {
-		grayScale[i] = make([]float64, bounds.Dy())
+// IsDeep tells if the image has 16 bits per channel, from its color model.
+func IsDeep(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, color.Alpha16Model:
+		return true
 	}
+	return false
+}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			// A color's RGBA method returns values in the range [0, 65535].
-			r, g, b, _ := img.At(x, y).RGBA()
-			grayScale[x - bounds.Min.X][y - bounds.Min.Y] = 0.2989 * float64(r) + 0.5870 * float64(g) + 0.1140 * float64(b)
-		}
+// NewImageLike returns an empty image of the given size with the depth of img: RGBA64 for the 16 bit images, so
+// their precision is kept through the processing, and RGBA otherwise.
+func NewImageLike(img image.Image, width int, height int) draw.Image {
+	if IsDeep(img) {
+		return image.NewRGBA64(image.Rect(0, 0, width, height))
 	}
-	return  grayScale
+	return image.NewRGBA(image.Rect(0, 0, width, height))
 }
 
-func SobelFilter(gray [][]float64) [][]float64 {
-	gx := [][]float64{
-		{-1, 0, 1},
-		{-2, 0, 2},
-		{-1, 0, 1},
+// CopyImage copies the image in a new image of NewImageLike whose bounds start from (0, 0).
+func CopyImage(img image.Image) draw.Image {
+	if view, ok := img.(*TransposedImage); ok {
+		return Transpose(view.Image)
 	}
-	gy := [][]float64{
-		{-1, -2, -1},
-		{0, 0, 0},
-		{1, 2, 1},
-	}
-	magnitude := make([][]float64, len(gray))
 
-	for x := range magnitude {
-		magnitude[x] = make([]float64, len(gray[x]))
+	bounds := img.Bounds()
+	ret := NewImageLike(img, bounds.Dx(), bounds.Dy())
+
+	// The lines of the types built by the lib are copied directly.
+	switch src := img.(type) {
+	case *image.RGBA:
+		dst := ret.(*image.RGBA)
+		for y := 0; y < bounds.Dy(); y++ {
+			copy(dst.Pix[dst.PixOffset(0, y):dst.PixOffset(bounds.Dx(), y)], src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y + y):])
+		}
+		return ret
+	case *image.RGBA64:
+		dst := ret.(*image.RGBA64)
+		for y := 0; y < bounds.Dy(); y++ {
+			copy(dst.Pix[dst.PixOffset(0, y):dst.PixOffset(bounds.Dx(), y)], src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y + y):])
+		}
+		return ret
 	}
 
-	for x := 1; x < len(gray) - 1; x ++ {
-		for y := 1; y < len(gray[x]) - 1; y ++ {
-			sx := CartesianProductSum(gx, gray, x, y)
-			sy := CartesianProductSum(gy, gray, x, y)
-
-			magnitude[x][y] = math.Sqrt(sx * sx + sy * sy)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ret.Set(x - bounds.Min.X, y - bounds.Min.Y, img.At(x, y))
 		}
 	}
-	return magnitude
+	return ret
 }
 
-func CartesianProductSum(g [][]float64, img [][]float64, x int, y int) float64 {
-	var res float64
-
-	for i := -1; i <= 1; i++ {
-		for j := -1; j <= 1; j++ {
-			res += g[i + 1][j + 1] * img[x +i][y + j]
-		}
-	}
+// GetGrayImage returns the luminance of the pixels. The colors are premultiplied by alpha, so the transparent pixels
+// are black, GetAlphaImage gives their opacity.
+func GetGrayImage(img image.Image) *Plane {
+	return PlaneFromImage(img, func(r, g, b, _ uint32) float64 {
+		// A color's RGBA method returns values in the range [0, 65535].
+		return 0.2989 * float64(r) + 0.5870 * float64(g) + 0.1140 * float64(b)
+	})
+}
 
-	return res
+// GetAlphaImage returns the opacity of the pixels in [0, 65535].
+func GetAlphaImage(img image.Image) *Plane {
+	return PlaneFromImage(img, func(_, _, _, a uint32) float64 {
+		return float64(a)
+	})
 }
 
-func PrintMagnitude(magnitude [][]float64, path string) error {
-	img := image.NewRGBA(image.Rect(0,0, len(magnitude), len(magnitude[0])))
-	for x := 0; x < len(magnitude); x ++ {
-		if len(magnitude[x]) != len(magnitude[0]) {
-			return errors.Wrapf (nil, "magnitude at line %v has value %v, when line 0 has %v", x, magnitude[x], magnitude[0])
-		}
-		for y := 0; y < len(magnitude[0]); y++ {
-			pixel := color.Gray16{uint16(magnitude[x][y])}
-			img.Set(x, y, pixel)
+// IsOpaque tells if all the pixels of the image are opaque, using the Opaque method of the image types of the
+// standard library when there is one.
+func IsOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
 		}
 	}
+	return true
+}
 
-	outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
-	if err != nil {
-		return errors.Wrapf(err, "could not create file at path '%v'", path)
-	}
-	defer outFile.Close()
-	return jpeg.Encode(outFile, img, nil)
+func SobelFilter(gray *Plane) *Plane {
+	return GradientFilter(gray, SobelX, SobelY, false)
 }
 
-func RotateClockLine(srcImg image.Image, poliLyne []Point) {
-	for i := 0; i < len(poliLyne); i++ {
-		oldX := poliLyne[i].X
-		poliLyne[i].X = poliLyne[i].Y
-		poliLyne[i].Y = srcImg.Bounds().Dx() - 1 - oldX
+// GradientFilter combines the responses to the kernels gx and gy with the L1 or the euclidean norm, the border
+// pixels are clamped.
+func GradientFilter(gray *Plane, gx [][]float64, gy [][]float64, l1 bool) *Plane {
+	magnitude := Convolve(gray, gx, BorderClamp)
+	gradientY := Convolve(gray, gy, BorderClamp)
+
+	for i, sx := range magnitude.Pix {
+		sy := gradientY.Pix[i]
+		if l1 {
+			magnitude.Pix[i] = math.Abs(sx) + math.Abs(sy)
+		} else {
+			magnitude.Pix[i] = math.Sqrt(sx * sx + sy * sy)
+		}
 	}
+	return magnitude
 }
 
-func RotateClock(srcImg image.RGBA) image.RGBA {
-	srcDim := srcImg.Bounds()
-	dstImage := image.NewRGBA(image.Rect(0, 0, srcDim.Dy(), srcDim.Dx()))
+// PrintMagnitude saves the plane as a 16 bit gray image, scaled so its maximum is white.
+func PrintMagnitude(magnitude *Plane, path string) error {
+	return WriteImage(path, magnitude.Image(), EncodeOptions{})
+}
 
-	for x := 0; x < srcDim.Dx(); x ++ {
-		for y := 0; y < srcDim.Dy(); y++ {
-			dstImage.Set(y, srcDim.Dx() - 1 - x, srcImg.At(x, y))
+// GetMask returns a plane with 1 on the pixels of the mask image which are white, meaning lighter than the middle
+// gray, and 0 elsewhere.
+func GetMask(img image.Image) *Plane {
+	mask := GetGrayImage(img)
+	for i, val := range mask.Pix {
+		if val > 32767 {
+			mask.Pix[i] = 1
+		} else {
+			mask.Pix[i] = 0
 		}
 	}
-
-	return *dstImage
+	return mask
 }
-
@@ -0,0 +1,85 @@
+package meta
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+)
+
+// StdStream is the path standing for the standard output, or the standard input when reading.
+const StdStream = "-"
+
+// Output is a file written atomically: the content goes to a temporary file of the same directory, which replaces the
+// path only on Commit, so a failure never leaves a partial file behind.
+type Output struct {
+	path      string
+	noClobber bool
+	file      *os.File
+	done      bool
+}
+
+// CreateOutput starts writing the file at path, or the standard output for StdStream. With noClobber, an existing
+// file is never replaced.
+func CreateOutput(path string, noClobber bool) (*Output, error) {
+	if path == StdStream {
+		return &Output{path: path, file: os.Stdout}, nil
+	}
+
+	if noClobber {
+		if _, err := os.Stat(path); err == nil {
+			return nil, errors.Errorf("the file '%v' already exists", path)
+		}
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "." + filepath.Base(path) + ".*.tmp")
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create a temporary file for '%v'", path)
+	}
+	return &Output{path: path, noClobber: noClobber, file: file}, nil
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	return o.file.Write(p)
+}
+
+// Commit moves the complete content at the path of the output.
+func (o *Output) Commit() error {
+	if o.path == StdStream {
+		o.done = true
+		return nil
+	}
+
+	if err := o.file.Sync(); err != nil {
+		return errors.Wrapf(err, "could not flush the content of '%v'", o.path)
+	}
+	if err := o.file.Close(); err != nil {
+		return errors.Wrapf(err, "could not close the temporary file of '%v'", o.path)
+	}
+	o.done = true
+
+	if o.noClobber {
+		// Unlike a rename, a link fails when the path was created in the meantime.
+		err := os.Link(o.file.Name(), o.path)
+		os.Remove(o.file.Name())
+		if err != nil {
+			return errors.Wrapf(err, "could not create the file '%v'", o.path)
+		}
+		return nil
+	}
+
+	if err := os.Rename(o.file.Name(), o.path); err != nil {
+		os.Remove(o.file.Name())
+		return errors.Wrapf(err, "could not replace the file '%v'", o.path)
+	}
+	return nil
+}
+
+// Close drops the temporary file when the output was not committed, so it can always be deferred.
+func (o *Output) Close() error {
+	if o.done || o.path == StdStream {
+		return nil
+	}
+	o.done = true
+	o.file.Close()
+	return os.Remove(o.file.Name())
+}
@@ -0,0 +1,106 @@
+package meta
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dirNames returns the names of the files of the directory.
+func dirNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestWriteImageReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.png")
+	if err := WriteImage(path, noise(64, 64), EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The smaller content must not keep the end of the larger one.
+	small := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	if err := WriteImage(path, small, EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var expected bytes.Buffer
+	if err := EncodeImage(&expected, small, "png", EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, expected.Bytes()) {
+		t.Errorf("the file has %v bytes, expected the %v bytes of the small image", len(content), expected.Len())
+	}
+	if names := dirNames(t, filepath.Dir(path)); len(names) != 1 {
+		t.Errorf("files left in the directory: %v", names)
+	}
+}
+
+func TestWriteImageNoClobber(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.png")
+	if err := WriteImage(path, noise(8, 8), EncodeOptions{NoClobber: true}); err != nil {
+		t.Fatalf("new file: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteImage(path, image.NewRGBA(image.Rect(0, 0, 4, 4)), EncodeOptions{NoClobber: true}); err == nil {
+		t.Errorf("an existing file was replaced with NoClobber")
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("the existing file was changed")
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("files left in the directory: %v", names)
+	}
+}
+
+func TestWriteImageFailedEncode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.jpg")
+	if err := os.WriteFile(path, []byte("previous content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteImage(path, noise(8, 8), EncodeOptions{JPEGQuality: 101}); err == nil {
+		t.Fatalf("the jpeg quality 101 was accepted")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "previous content" {
+		t.Errorf("the file was changed by the failed encode: %q", content)
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("files left in the directory: %v", names)
+	}
+}
+
+// noise returns an image whose bytes are all different enough to be badly compressed.
+func noise(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 7919 % 251)
+	}
+	return img
+}
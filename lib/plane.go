@@ -0,0 +1,165 @@
+package meta
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Plane is a dense matrix of values indexed (x, y) like the pixels of an image. The values are stored line after
+// line in Pix, the line y starts at y*Stride, so a sub-view shares the values of its parent. The energies, the masks
+// and the dynamic programming tables are planes.
+type Plane struct {
+	Width  int
+	Height int
+	Stride int
+	Pix    []float64
+}
+
+// NewPlane returns a plane of zeros.
+func NewPlane(width int, height int) *Plane {
+	return &Plane{Width: width, Height: height, Stride: width, Pix: make([]float64, width * height)}
+}
+
+func (p *Plane) At(x int, y int) float64 {
+	return p.Pix[y * p.Stride + x]
+}
+
+func (p *Plane) Set(x int, y int, val float64) {
+	p.Pix[y * p.Stride + x] = val
+}
+
+// Row returns the values of the line y, sharing them with the plane.
+func (p *Plane) Row(y int) []float64 {
+	return p.Pix[y * p.Stride:y * p.Stride + p.Width]
+}
+
+// SubPlane returns the view of the rectangle r of the plane, without copying the values.
+func (p *Plane) SubPlane(r image.Rectangle) *Plane {
+	r = r.Intersect(image.Rect(0, 0, p.Width, p.Height))
+	if r.Empty() {
+		return &Plane{}
+	}
+	start := r.Min.Y * p.Stride + r.Min.X
+	end := (r.Max.Y - 1) * p.Stride + r.Max.X
+	return &Plane{Width: r.Dx(), Height: r.Dy(), Stride: p.Stride, Pix: p.Pix[start:end]}
+}
+
+// Clone copies the plane in a new dense plane.
+func (p *Plane) Clone() *Plane {
+	ret := NewPlane(p.Width, p.Height)
+	for y := 0; y < p.Height; y++ {
+		copy(ret.Row(y), p.Row(y))
+	}
+	return ret
+}
+
+// Transpose returns a new plane whose value (y, x) is the value (x, y) of the plane, nil stays nil.
+func (p *Plane) Transpose() *Plane {
+	if p == nil {
+		return nil
+	}
+	ret := NewPlane(p.Height, p.Width)
+	for y := 0; y < p.Height; y++ {
+		for x, val := range p.Row(y) {
+			ret.Pix[x * ret.Stride + y] = val
+		}
+	}
+	return ret
+}
+
+// Max returns the maximum value of the plane, 0 for an empty plane.
+func (p *Plane) Max() float64 {
+	if p.Width == 0 || p.Height == 0 {
+		return 0
+	}
+	ret := math.Inf(-1)
+	for y := 0; y < p.Height; y++ {
+		for _, val := range p.Row(y) {
+			ret = math.Max(ret, val)
+		}
+	}
+	return ret
+}
+
+// Image converts the plane in a 16 bit gray image, scaled so the maximum is white and the negative values are black.
+func (p *Plane) Image() *image.Gray16 {
+	maxVal := p.Max()
+	img := image.NewGray16(image.Rect(0, 0, p.Width, p.Height))
+	for y := 0; y < p.Height; y++ {
+		for x, val := range p.Row(y) {
+			if maxVal > 0 {
+				val = math.Max(val, 0) / maxVal * 65535
+			} else {
+				val = 0
+			}
+			img.SetGray16(x, y, color.Gray16{Y: uint16(math.Round(val))})
+		}
+	}
+	return img
+}
+
+// PlaneFromImage builds a plane with the value of every pixel of the image given by the function, which receives the
+// 16 bit premultiplied values returned by color.RGBA.
+func PlaneFromImage(img image.Image, value func(r, g, b, a uint32) float64) *Plane {
+	ret := NewPlane(img.Bounds().Dx(), img.Bounds().Dy())
+	forEachPixel(img, func(i int, r, g, b, a uint32) {
+		ret.Pix[i] = value(r, g, b, a)
+	})
+	return ret
+}
+
+// PlanesFromImage is PlaneFromImage for n planes built in one pass over the image, the function writes the n values
+// of every pixel in out.
+func PlanesFromImage(img image.Image, n int, values func(r, g, b, a uint32, out []float64)) []*Plane {
+	ret := make([]*Plane, n)
+	for i := range ret {
+		ret[i] = NewPlane(img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	out := make([]float64, n)
+	forEachPixel(img, func(i int, r, g, b, a uint32) {
+		values(r, g, b, a, out)
+		for c, plane := range ret {
+			plane.Pix[i] = out[c]
+		}
+	})
+	return ret
+}
+
+// forEachPixel calls f with the index of every pixel in a dense plane of the size of the image and its 16 bit
+// premultiplied values. The direct access to the pixels of the types built by the lib avoids an allocation by pixel.
+func forEachPixel(img image.Image, f func(i int, r, g, b, a uint32)) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	switch src := img.(type) {
+	case *image.RGBA:
+		for y := 0; y < bounds.Dy(); y++ {
+			pix := src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y + y):]
+			for x := 0; x < width; x++ {
+				r, g, b, a := uint32(pix[4 * x]), uint32(pix[4 * x + 1]), uint32(pix[4 * x + 2]), uint32(pix[4 * x + 3])
+				f(y * width + x, r | r << 8, g | g << 8, b | b << 8, a | a << 8)
+			}
+		}
+	case *image.RGBA64:
+		for y := 0; y < bounds.Dy(); y++ {
+			pix := src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y + y):]
+			for x := 0; x < width; x++ {
+				p := pix[8 * x:8 * x + 8]
+				f(
+					y * width + x,
+					uint32(p[0]) << 8 | uint32(p[1]),
+					uint32(p[2]) << 8 | uint32(p[3]),
+					uint32(p[4]) << 8 | uint32(p[5]),
+					uint32(p[6]) << 8 | uint32(p[7]),
+				)
+			}
+		}
+	default:
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < width; x++ {
+				r, g, b, a := img.At(bounds.Min.X + x, bounds.Min.Y + y).RGBA()
+				f(y * width + x, r, g, b, a)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package meta
+
+import (
+	"github.com/pkg/errors"
+	"math"
+	"sort"
+)
+
+// Polygon is a closed outline, the last point is joined with the first one. It may be concave or self touching.
+type Polygon []Point
+
+// FillRule decides which pixels are inside a set of polygons which overlap or contain each other.
+type FillRule int
+
+const (
+	// EvenOdd fills the pixels crossed by an odd number of edges on their left, so a polygon inside another is a hole.
+	EvenOdd FillRule = iota
+	// NonZero fills the pixels with a non zero winding number, so only a polygon inside another with the opposite
+	// orientation is a hole.
+	NonZero
+)
+
+// FillRuleByName parses the cli names of the fill rules.
+func FillRuleByName(name string) (FillRule, error) {
+	switch name {
+	case "even-odd":
+		return EvenOdd, nil
+	case "non-zero":
+		return NonZero, nil
+	}
+	return 0, errors.Errorf("unknown fill rule '%v', expected even-odd or non-zero", name)
+}
+
+type crossing struct {
+	x float64
+	// winding is +1 for an edge going down and -1 for an edge going up.
+	winding int
+}
+
+// Rasterize returns a width x height plane with 1 on the pixels which are inside the polygons and 0 elsewhere.
+// Every line of pixels is intersected with all the edges, an edge covers the lines in [min y, max y) so the shared
+// vertices are counted once. The pixels between two crossings are filled including the ones exactly on the edges.
+// The spans miss the bottom vertices and the horizontal edges, so the edges are drawn after them too.
+func Rasterize(polygons []Polygon, rule FillRule, width int, height int) *Plane {
+	mask := NewPlane(width, height)
+
+	var crossings []crossing
+	for y := 0; y < height; y++ {
+		crossings = crossings[:0]
+		fy := float64(y)
+
+		for _, polygon := range polygons {
+			for i := range polygon {
+				a := polygon[i]
+				b := polygon[(i + 1) % len(polygon)]
+				if a.Y == b.Y {
+					continue
+				}
+
+				winding := 1
+				if a.Y > b.Y {
+					a, b = b, a
+					winding = -1
+				}
+				if y < a.Y || y >= b.Y {
+					continue
+				}
+
+				x := float64(a.X) + (fy - float64(a.Y)) * float64(b.X - a.X) / float64(b.Y - a.Y)
+				crossings = append(crossings, crossing{x: x, winding: winding})
+			}
+		}
+
+		sort.Slice(crossings, func(i, j int) bool {
+			return crossings[i].x < crossings[j].x
+		})
+
+		inside := 0
+		for i := 0; i + 1 < len(crossings); i++ {
+			if rule == EvenOdd {
+				inside ^= 1
+			} else {
+				inside += crossings[i].winding
+			}
+			if inside == 0 {
+				continue
+			}
+
+			left := int(math.Ceil(crossings[i].x))
+			right := int(math.Floor(crossings[i + 1].x))
+			for x := left; x <= right; x++ {
+				if x >= 0 && x < width {
+					mask.Set(x, y, 1)
+				}
+			}
+		}
+	}
+
+	for _, polygon := range polygons {
+		for i := range polygon {
+			drawEdge(mask, polygon[i], polygon[(i + 1) % len(polygon)])
+		}
+	}
+	return mask
+}
+
+// drawEdge sets the pixels closest to the segment from a to b, both ends included, one per step on its longer axis.
+func drawEdge(mask *Plane, a Point, b Point) {
+	dx, dy := b.X - a.X, b.Y - a.Y
+	steps := int(math.Max(math.Abs(float64(dx)), math.Abs(float64(dy))))
+	for i := 0; i <= steps; i++ {
+		x, y := a.X, a.Y
+		if steps > 0 {
+			x += int(math.Round(float64(i * dx) / float64(steps)))
+			y += int(math.Round(float64(i * dy) / float64(steps)))
+		}
+		if x >= 0 && x < mask.Width && y >= 0 && y < mask.Height {
+			mask.Set(x, y, 1)
+		}
+	}
+}
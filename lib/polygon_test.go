@@ -0,0 +1,44 @@
+package meta
+
+import (
+	"testing"
+)
+
+func TestRasterize(t *testing.T) {
+	square := Polygon{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	inner := Polygon{{X: 2, Y: 2}, {X: 8, Y: 2}, {X: 8, Y: 8}, {X: 2, Y: 8}}
+	innerReversed := Polygon{{X: 2, Y: 2}, {X: 2, Y: 8}, {X: 8, Y: 8}, {X: 8, Y: 2}}
+
+	tests := []struct {
+		name     string
+		polygons []Polygon
+		rule     FillRule
+		size     int
+		count    int
+	}{
+		// The square of side 10 covers the pixels 0 to 10 on both axes, bottom line and right column included.
+		{"axis aligned", []Polygon{square}, EvenOdd, 12, 11 * 11},
+		// An L of 5 full lines and 6 lines of 5 pixels, whose inner corner is at (4, 4).
+		{"concave", []Polygon{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 10}, {X: 0, Y: 10}}},
+			EvenOdd, 12, 5 * 11 + 6 * 5},
+		// The border of the hole is drawn, only the 5x5 pixels strictly inside it are left out.
+		{"hole", []Polygon{square, inner}, EvenOdd, 12, 11 * 11 - 5 * 5},
+		{"nested non zero", []Polygon{square, inner}, NonZero, 12, 11 * 11},
+		{"hole non zero", []Polygon{square, innerReversed}, NonZero, 12, 11 * 11 - 5 * 5},
+		// Only the part inside the image is filled.
+		{"clipped", []Polygon{square}, EvenOdd, 6, 6 * 6},
+	}
+
+	for _, test := range tests {
+		mask := Rasterize(test.polygons, test.rule, test.size, test.size)
+		count := 0
+		for _, val := range mask.Pix {
+			if val != 0 {
+				count++
+			}
+		}
+		if count != test.count {
+			t.Errorf("%v: %v pixels filled, expected %v", test.name, count, test.count)
+		}
+	}
+}
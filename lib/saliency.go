@@ -0,0 +1,188 @@
+package meta
+
+import (
+	"github.com/pkg/errors"
+	"image"
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+// SaliencyFunc computes how much every pixel of an image draws the eye, in [0, 1].
+type SaliencyFunc interface {
+	Saliency(img image.Image) *Plane
+}
+
+// SpectralResidualSaliency is the saliency of Hou and Zhang 2007: the parts of the log spectrum of a small copy of
+// the image which stand out of their neighbourhood are the unexpected content, brought back to the image space with
+// the original phase.
+type SpectralResidualSaliency struct {
+	// Size is the side of the square copy of the image whose spectrum is computed, rounded up to a power of two.
+	Size int
+	// Sigma of the gaussian smoothing the map, in pixels of the square copy.
+	Sigma float64
+}
+
+func (s SpectralResidualSaliency) Saliency(img image.Image) *Plane {
+	n := 1
+	for n < s.Size {
+		n *= 2
+	}
+
+	bounds := img.Bounds()
+	small := resizePlane(GetGrayImage(img), n, n)
+	if isConstant(small) {
+		// Only the rounding errors of the transforms would be left to normalize.
+		return NewPlane(bounds.Dx(), bounds.Dy())
+	}
+
+	spectrum := make([]complex128, n * n)
+	for i, val := range small.Pix {
+		spectrum[i] = complex(val, 0)
+	}
+	fft2(spectrum, n, n, false)
+
+	// The spectrum is periodic, so its average wraps around.
+	logAmplitude := NewPlane(n, n)
+	for i, c := range spectrum {
+		logAmplitude.Pix[i] = math.Log(cmplx.Abs(c) + 1e-9)
+	}
+	average := Convolve(logAmplitude, boxKernel(3), BorderWrap)
+	for i, c := range spectrum {
+		spectrum[i] = cmplx.Rect(math.Exp(logAmplitude.Pix[i] - average.Pix[i]), cmplx.Phase(c))
+	}
+	fft2(spectrum, n, n, true)
+
+	for i, c := range spectrum {
+		small.Pix[i] = real(c) * real(c) + imag(c) * imag(c)
+	}
+	small = GaussianBlur(small, s.Sigma, BorderReflect)
+
+	return normalizePlane(resizePlane(small, bounds.Dx(), bounds.Dy()))
+}
+
+// FrequencyTunedSaliency is the saliency of Achanta et al. 2009: the distance in CIE Lab between every pixel, slightly
+// blurred, and the mean color of the image. It keeps the whole salient objects with their sharp borders.
+type FrequencyTunedSaliency struct {
+	// Sigma of the gaussian removing the noise and the fine texture, in pixels.
+	Sigma float64
+}
+
+func (f FrequencyTunedSaliency) Saliency(img image.Image) *Plane {
+	planes := ColorPlanes(img, Lab)
+	ret := NewPlane(planes[0].Width, planes[0].Height)
+	for _, plane := range planes {
+		mean := float64(0)
+		for _, val := range plane.Pix {
+			mean += val
+		}
+		mean /= float64(len(plane.Pix))
+
+		blurred := GaussianBlur(plane, f.Sigma, BorderReflect)
+		for i, val := range blurred.Pix {
+			ret.Pix[i] += (val - mean) * (val - mean)
+		}
+	}
+	for i, val := range ret.Pix {
+		ret.Pix[i] = math.Sqrt(val)
+	}
+	return normalizePlane(ret)
+}
+
+var saliencies = map[string]SaliencyFunc{
+	"spectral-residual": SpectralResidualSaliency{Size: 64, Sigma: 3},
+	"frequency-tuned":   FrequencyTunedSaliency{Sigma: 1},
+}
+
+// SaliencyByName returns one of the built-in saliencies with its default parameters.
+func SaliencyByName(name string) (SaliencyFunc, error) {
+	saliency, ok := saliencies[name]
+	if !ok {
+		return nil, errors.Errorf("unknown saliency '%v', expected one of %v", name, SaliencyNames())
+	}
+	return saliency, nil
+}
+
+// SaliencyNames returns the sorted names accepted by SaliencyByName.
+func SaliencyNames() []string {
+	var names []string
+	for name := range saliencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// boxKernel is the n x n kernel of the mean.
+func boxKernel(n int) [][]float64 {
+	kernel := make([][]float64, n)
+	for i := range kernel {
+		kernel[i] = make([]float64, n)
+		for j := range kernel[i] {
+			kernel[i][j] = 1 / float64(n * n)
+		}
+	}
+	return kernel
+}
+
+func isConstant(p *Plane) bool {
+	for _, val := range p.Pix {
+		if val != p.Pix[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizePlane stretches the values of the plane to [0, 1], a constant plane becomes zero.
+func normalizePlane(p *Plane) *Plane {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, val := range p.Pix {
+		min = math.Min(min, val)
+		max = math.Max(max, val)
+	}
+	for i, val := range p.Pix {
+		if max > min {
+			p.Pix[i] = (val - min) / (max - min)
+		} else {
+			p.Pix[i] = 0
+		}
+	}
+	return p
+}
+
+// resizePlane resamples the plane to w x h values, averaging the covered values when shrinking and interpolating
+// linearly when growing.
+func resizePlane(p *Plane, w int, h int) *Plane {
+	return resizeLines(resizeLines(p, w).Transpose(), h).Transpose()
+}
+
+// resizeLines resamples every line of the plane to w values.
+func resizeLines(p *Plane, w int) *Plane {
+	ret := NewPlane(w, p.Height)
+	scale := float64(p.Width) / float64(w)
+	for y := 0; y < p.Height; y++ {
+		src, dst := p.Row(y), ret.Row(y)
+		for x := range dst {
+			if w < p.Width {
+				lo, hi := x * p.Width / w, (x + 1) * p.Width / w
+				sum := float64(0)
+				for _, val := range src[lo:hi] {
+					sum += val
+				}
+				dst[x] = sum / float64(hi - lo)
+				continue
+			}
+
+			pos := math.Max(0, (float64(x) + 0.5) * scale - 0.5)
+			i := int(pos)
+			if i >= p.Width - 1 {
+				dst[x] = src[p.Width - 1]
+				continue
+			}
+			frac := pos - float64(i)
+			dst[x] = src[i] * (1 - frac) + src[i + 1] * frac
+		}
+	}
+	return ret
+}
@@ -0,0 +1,110 @@
+package meta
+
+import (
+	"image"
+)
+
+// SkinDetector finds the regions of skin color of an image, a cheap guess of the faces and the people whose
+// distortion is the most visible.
+type SkinDetector struct {
+	// MinFraction drops the connected groups of skin pixels smaller than this fraction of the image, like the
+	// scattered pixels of wood or sand.
+	MinFraction float64
+	// Radius of the dilation growing the remaining groups into blobs, in pixels, so the eyes, the mouth and the
+	// borders of the faces are kept with the skin around them.
+	Radius int
+}
+
+// IsSkinColor tells if a sRGB color in [0, 1] has the chroma of the skin in YCbCr, the ranges of Chai and Ngan 1999,
+// together with the hue and saturation of the skin in HSV, which removes the dark and the washed out colors.
+func IsSkinColor(r float64, g float64, b float64) bool {
+	_, cb, cr := RGBToYCbCr(r, g, b)
+	if cb < -51.0 / 255 || cb > -1.0 / 255 || cr < 5.0 / 255 || cr > 45.0 / 255 {
+		return false
+	}
+	h, s, v := RGBToHSV(r, g, b)
+	return (h <= 50.0 / 360 || h >= 340.0 / 360) && s >= 0.2 && s <= 0.75 && v >= 0.2
+}
+
+// Detect returns a plane with 1 on the pixels of the blobs of skin and 0 elsewhere, like GetMask.
+func (s SkinDetector) Detect(img image.Image) *Plane {
+	skin := PlaneFromImage(img, func(r, g, b, _ uint32) float64 {
+		if IsSkinColor(float64(r) / 65535, float64(g) / 65535, float64(b) / 65535) {
+			return 1
+		}
+		return 0
+	})
+	removeSmallGroups(skin, int(s.MinFraction * float64(skin.Width * skin.Height)))
+	if s.Radius > 0 {
+		return dilateLines(dilateLines(skin, s.Radius).Transpose(), s.Radius).Transpose()
+	}
+	// The kept groups are marked with 2 by removeSmallGroups.
+	for i, val := range skin.Pix {
+		if val != 0 {
+			skin.Pix[i] = 1
+		}
+	}
+	return skin
+}
+
+// removeSmallGroups clears the groups of 8-connected pixels of value 1 which have less than minSize pixels.
+func removeSmallGroups(p *Plane, minSize int) {
+	if minSize <= 1 {
+		return
+	}
+
+	// The visited pixels get 2 for the kept groups and 0 for the removed ones.
+	var group, stack []int
+	for start, val := range p.Pix {
+		if val != 1 {
+			continue
+		}
+
+		group = group[:0]
+		stack = append(stack[:0], start)
+		p.Pix[start] = 2
+		for len(stack) > 0 {
+			i := stack[len(stack) - 1]
+			stack = stack[:len(stack) - 1]
+			group = append(group, i)
+
+			x, y := i % p.Width, i / p.Width
+			for ny := y - 1; ny <= y + 1; ny++ {
+				for nx := x - 1; nx <= x + 1; nx++ {
+					if nx < 0 || ny < 0 || nx >= p.Width || ny >= p.Height || p.Pix[ny * p.Width + nx] != 1 {
+						continue
+					}
+					p.Pix[ny * p.Width + nx] = 2
+					stack = append(stack, ny * p.Width + nx)
+				}
+			}
+		}
+
+		if len(group) < minSize {
+			for _, i := range group {
+				p.Pix[i] = 0
+			}
+		}
+	}
+}
+
+// dilateLines sets on every line the values which have a non zero value at most radius pixels away.
+func dilateLines(p *Plane, radius int) *Plane {
+	ret := NewPlane(p.Width, p.Height)
+	counts := make([]int, p.Width + 1)
+	for y := 0; y < p.Height; y++ {
+		src, dst := p.Row(y), ret.Row(y)
+		for x, val := range src {
+			counts[x + 1] = counts[x]
+			if val != 0 {
+				counts[x + 1]++
+			}
+		}
+		for x := range dst {
+			if counts[ClampInt(x + radius + 1, 0, p.Width)] > counts[ClampInt(x - radius, 0, p.Width)] {
+				dst[x] = 1
+			}
+		}
+	}
+	return ret
+}
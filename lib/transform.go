@@ -0,0 +1,110 @@
+package meta
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// TransposedImage is a view of Image with the axes swapped: its pixel (x, y) is the pixel (y, x) of Image, relative
+// to the bounds of Image. The seam carving finds the horizontal seams as the vertical seams of this view. Making the
+// view copies nothing, but reading it pixel by pixel is slow, so the code which walks it many times makes a dense
+// copy with CopyImage, which moves the bytes directly.
+type TransposedImage struct {
+	Image image.Image
+}
+
+// Transposed returns the transposed view of the image, or the original image for a view.
+func Transposed(img image.Image) image.Image {
+	if view, ok := img.(*TransposedImage); ok {
+		return view.Image
+	}
+	return &TransposedImage{Image: img}
+}
+
+func (t *TransposedImage) ColorModel() color.Model {
+	return t.Image.ColorModel()
+}
+
+func (t *TransposedImage) Bounds() image.Rectangle {
+	bounds := t.Image.Bounds()
+	return image.Rect(0, 0, bounds.Dy(), bounds.Dx())
+}
+
+func (t *TransposedImage) At(x int, y int) color.Color {
+	bounds := t.Image.Bounds()
+	return t.Image.At(bounds.Min.X + y, bounds.Min.Y + x)
+}
+
+// Transpose copies the image with the axes swapped.
+func Transpose(img image.Image) draw.Image {
+	return remapImage(img, true, func(x, y int) (int, int) { return y, x })
+}
+
+// Rotate90 turns the image a quarter clockwise.
+func Rotate90(img image.Image) draw.Image {
+	h := img.Bounds().Dy()
+	return remapImage(img, true, func(x, y int) (int, int) { return y, h - 1 - x })
+}
+
+// Rotate180 turns the image upside down.
+func Rotate180(img image.Image) draw.Image {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	return remapImage(img, false, func(x, y int) (int, int) { return w - 1 - x, h - 1 - y })
+}
+
+// Rotate270 turns the image a quarter counterclockwise.
+func Rotate270(img image.Image) draw.Image {
+	w := img.Bounds().Dx()
+	return remapImage(img, true, func(x, y int) (int, int) { return w - 1 - y, x })
+}
+
+// remapImage builds a new image whose pixel (x, y) is the pixel source(x, y) of img, relative to its bounds.
+// The dimensions are swapped when transposed. The result is allocated once and the pixels of the types built by the
+// lib are copied as bytes.
+func remapImage(img image.Image, transposed bool, source func(x, y int) (int, int)) draw.Image {
+	if view, ok := img.(*TransposedImage); ok {
+		// The view is folded in the mapping, so the pixels are read from the image under it.
+		return remapImage(view.Image, !transposed, func(x, y int) (int, int) {
+			srcX, srcY := source(x, y)
+			return srcY, srcX
+		})
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if transposed {
+		w, h = h, w
+	}
+
+	dstImage := NewImageLike(img, w, h)
+	switch src := img.(type) {
+	case *image.RGBA:
+		remapPix(src.Pix, src.PixOffset, dstImage.(*image.RGBA).Pix, w, h, 4, bounds.Min, source)
+		return dstImage
+	case *image.RGBA64:
+		remapPix(src.Pix, src.PixOffset, dstImage.(*image.RGBA64).Pix, w, h, 8, bounds.Min, source)
+		return dstImage
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			srcX, srcY := source(x, y)
+			dstImage.Set(x, y, img.At(bounds.Min.X + srcX, bounds.Min.Y + srcY))
+		}
+	}
+	return dstImage
+}
+
+// remapPix is remapImage on the bytes of a dense destination of w x h pixels of the given size.
+func remapPix(src []byte, offset func(x, y int) int, dst []byte, w int, h int, size int, min image.Point,
+	source func(x, y int) (int, int)) {
+	for y := 0; y < h; y++ {
+		line := dst[y * w * size:(y + 1) * w * size]
+		for x := 0; x < w; x++ {
+			srcX, srcY := source(x, y)
+			i := offset(min.X + srcX, min.Y + srcY)
+			copy(line[x * size:(x + 1) * size], src[i:i + size])
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/nfnt/resize"
 
 	"computer_vision/lib"
+	"computer_vision/project1/seamcarve"
 )
 
 func AmplificationImageContent() *cobra.Command {
@@ -19,7 +20,7 @@ func AmplificationImageContent() *cobra.Command {
 		Args: cobra.ExactArgs(2),
 		RunE: func(_ *cobra.Command, args []string) error {
 			imgPath := args[0]
-			img, err := meta.GetImageFromPath(imgPath)
+			img, err := meta.DecodeImageFromPath(imgPath, decodeOptions())
 			if err != nil {
 				return errors.Wrapf(err, "could not get an image obj from path '%v'", imgPath)
 			}
@@ -35,7 +36,16 @@ func AmplificationImageContent() *cobra.Command {
 
 			img = resize.Resize(uint(img.Bounds().Dx() + surpDimX), uint(img.Bounds().Dy() + surpDimY), img, resize.Lanczos3)
 
-			img, err = proceedErase(img, surpDimX, surpDimY, *modeResize)
+			opts, skin, err := carverOptions(initImg.Bounds().Size(), img)
+			if err != nil {
+				return errors.Wrapf(err, "could not configure the seam carving")
+			}
+			if err := printSkinDebug(img, skin); err != nil {
+				return err
+			}
+			carver := seamcarve.NewCarver(opts)
+
+			img, err = carver.Shrink(img, surpDimX, surpDimY)
 			if err != nil {
 				return errors.Wrapf(err, "failed to process the erase of %vx%v pixels", surpDimX, surpDimY)
 			}
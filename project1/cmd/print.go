@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"computer_vision/lib"
+)
+
+const pixelSpace = 10
+
+func printImage(finalImg image.Image, initImg image.Image, output string) error {
+	opts, err := encodeOptions()
+	if err != nil {
+		return err
+	}
+
+	clasicImg := resize.Resize(uint(finalImg.Bounds().Dx()), uint(finalImg.Bounds().Dy()), initImg, resize.Lanczos3)
+
+	newRect := image.Rectangle{
+		Max: image.Point{
+			X: max(initImg.Bounds().Dx(), finalImg.Bounds().Dx(), clasicImg.Bounds().Dx()),
+			Y: initImg.Bounds().Dy() + pixelSpace + finalImg.Bounds().Dy() + pixelSpace + clasicImg.Bounds().Dy(),
+		},
+	}
+	prtImage := meta.NewImageLike(finalImg, newRect.Dx(), newRect.Dy())
+
+	addImage(prtImage, initImg, 0, 0)
+	addImage(prtImage, finalImg, 0, initImg.Bounds().Dy() + pixelSpace)
+	addImage(prtImage, clasicImg, 0, initImg.Bounds().Dy() + pixelSpace + finalImg.Bounds().Dy() + pixelSpace)
+
+	return meta.WriteImage(output, prtImage, opts)
+}
+
+// printSkinDebug saves the skin region for --skin-debug, nothing without the flag.
+func printSkinDebug(img image.Image, skin *meta.Plane) error {
+	if *skinDebugPath == "" {
+		return nil
+	}
+	if err := printSkin(img, skin, *skinDebugPath); err != nil {
+		return errors.Wrapf(err, "could not save the skin regions to '%v'", *skinDebugPath)
+	}
+	return nil
+}
+
+// printSkin saves the image with the pixels out of the skin region, the zeros of its plane, darkened to a third.
+func printSkin(img image.Image, skin *meta.Plane, output string) error {
+	opts, err := encodeOptions()
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	prtImage := meta.NewImageLike(img, bounds.Dx(), bounds.Dy())
+	for y := 0; y < skin.Height; y++ {
+		for x, val := range skin.Row(y) {
+			r, g, b, a := img.At(bounds.Min.X + x, bounds.Min.Y + y).RGBA()
+			if val == 0 {
+				r, g, b = r / 3, g / 3, b / 3
+			}
+			prtImage.Set(x, y, color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)})
+		}
+	}
+	return meta.WriteImage(output, prtImage, opts)
+}
+
+func decodeOptions() meta.DecodeOptions {
+	return meta.DecodeOptions{KeepOrientation: *noAutoOrient}
+}
+
+func encodeOptions() (meta.EncodeOptions, error) {
+	compression, err := meta.PNGCompressionByName(*pngCompression)
+	if err != nil {
+		return meta.EncodeOptions{}, err
+	}
+	return meta.EncodeOptions{
+		Format:         *outputFormat,
+		JPEGQuality:    *jpegQuality,
+		PNGCompression: compression,
+		NoClobber:      *noClobber,
+	}, nil
+}
+
+func addImage(act draw.Image, appImage image.Image, xstart int, ystart int) {
+	for x := 0; x < appImage.Bounds().Dx(); x++ {
+		for y := 0; y < appImage.Bounds().Dy(); y++ {
+			act.Set(x + xstart, y + ystart, appImage.At(x, y))
+		}
+	}
+}
+
+func max(x, y, z int) int {
+	if x > y && x > z {
+		return x
+	}
+	if y > x && y > z {
+		return y
+	}
+	return z
+}
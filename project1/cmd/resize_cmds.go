@@ -2,16 +2,43 @@ package cmd
 
 import (
 	"computer_vision/lib"
+	"fmt"
+	"computer_vision/project1/seamcarve"
+	"github.com/nfnt/resize"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"image"
+	"os"
 	"strconv"
+	"strings"
 )
 
 var (
-	outputPath = pflag.StringP("output", "o", "result.jpeg", "The path where to save the output jpeg picture.")
-	modeResize = pflag.StringP("mode", "m", "dynamics", "The mode of erasing one column of pixels.\n1.'dynamics' for doing a dynamic programming approach\n2. 'greedy' for doing a greedy approach\n3. (anything else) for doing a random approach\n")
+	outputPath = pflag.StringP("output", "o", "result.jpeg", "The path where to save the output picture, its extension gives the format.\n'-' writes it on the standard output, in png unless --format is given.")
+	noClobber = pflag.Bool("no-clobber", false, "Fail instead of replacing an existing output file.")
+	outputFormat = pflag.String("format", "", "The format of the output picture, one of "+strings.Join(meta.EncodeFormats, ", ")+", instead of the one of the output extension.")
+	jpegQuality = pflag.Int("jpeg-quality", 75, "The quality of the jpeg output, from 1 to 100.")
+	pngCompression = pflag.String("png-compression", "default", "The compression level of the png output, one of default, none, fast, best.")
+	modeResize = pflag.StringP("mode", "m", "dynamics", "The mode of erasing one column of pixels.\n1.'dynamics' for doing a dynamic programming approach\n2. 'greedy' for doing a greedy approach\n3. 'forward' for doing a dynamic programming approach on the forward energy\n4. (anything else) for doing a random approach\n")
+	energyName = pflag.String("energy", "", "The energy of the pixels, one of "+strings.Join(meta.EnergyNames(), ", ")+".\nBy default 'sobel', or 'zero' for the forward mode where it is only added to the forward cost.")
+	seamOrder = pflag.String("order", "vertical-first", "The order of the vertical and horizontal seams for decrease and amplification, one of\nvertical-first, horizontal-first, alternate, optimal.\n'optimal' uses the transport map of the paper and is much slower.")
+	verbose = pflag.BoolP("verbose", "v", false, "Report the details of the processing on stderr.")
+	removeMaskPath = pflag.String("remove-mask", "", "The path of a mask image of the same size as the input, the seams are forced through its white pixels.")
+	protectMaskPath = pflag.String("protect-mask", "", "The path of a mask image of the same size as the input, the seams are kept out of its white pixels.")
+	fillRule = pflag.String("fill-rule", "even-odd", "The rule deciding the inside of the polygons of erase, 'even-odd' or 'non-zero'.\nWith 'even-odd' a polygon inside another one is a hole, with 'non-zero' only if it has the opposite orientation.")
+	adaptiveErase = pflag.Bool("adaptive", false, "Let erase choose the direction of every seam by its cost and stop when nothing is left of the object.")
+	restoreSize = pflag.Bool("restore-size", false, "After erase removed the object, insert the same number of seams so the output keeps the size of the input.")
+	noAutoOrient = pflag.Bool("no-auto-orient", false, "Keep the jpeg images as stored, without applying the orientation of their EXIF segment.")
+	transparentZero = pflag.Bool("transparent-zero", false, "Give no energy to the fully transparent pixels, so the seams go through the empty space first.")
+	saliencyName = pflag.String("saliency", "spectral-residual", "The saliency blended in the energy by --saliency-weight, one of "+strings.Join(meta.SaliencyNames(), ", ")+".")
+	saliencyWeight = pflag.Float64("saliency-weight", 0, "The weight of the saliency added to the energy, relative to the strongest energy of the image.\nWith 1 the most salient pixels cost as much as the strongest edge, 0 disables the saliency.")
+	protectSkin = pflag.Bool("protect-skin", false, "Keep the seams out of the regions of skin color, a guess of the faces and the people of the image.")
+	skinRadius = pflag.Int("skin-radius", 8, "The number of pixels by which the regions of skin color are grown, so the eyes and the borders of the faces are kept too.")
+	skinDebugPath = pflag.String("skin-debug", "", "The path where to save the image with the regions of skin color highlighted, the rest darkened.")
+	importancePath = pflag.String("importance", "", "The path of a gray image of the same size as the input: its lighter pixels are kept and its darker pixels are removed first,\nthe middle gray changes nothing.")
+	importanceMode = pflag.String("importance-mode", "multiply", "The way the importance changes the energy, 'multiply' scales it by 1 + weight*(2*gray - 1),\n'add' adds weight*(2*gray - 1) times the strongest energy of the image.")
+	importanceWeight = pflag.Float64("importance-weight", 1, "The strength of the importance map.")
 	maxIncreaseDiv = pflag.Int("max-increase-div", 2, "No more than image_size/<value> pixels will be added in the same time for increasing size commands.")
 	)
 
@@ -22,7 +49,7 @@ func DecreaseSizeImage() *cobra.Command {
 		Args: cobra.ExactArgs(3),
 		RunE: func(_ *cobra.Command, args []string) error {
 			imgPath := args[0]
-			img, err := meta.GetImageFromPath(imgPath)
+			img, err := meta.DecodeImageFromPath(imgPath, decodeOptions())
 			if err != nil {
 				return errors.Wrapf(err, "could not get an image obj from path '%v'", imgPath)
 			}
@@ -38,7 +65,16 @@ func DecreaseSizeImage() *cobra.Command {
 				return errors.Wrapf(err, "could not parse as integer arg received '%v'", args[1])
 			}
 
-			img, err = proceedErase(img, noPixelsWidthToErase, noPixelsHeightToErase, *modeResize)
+			opts, skin, err := carverOptions(img.Bounds().Size(), img)
+			if err != nil {
+				return errors.Wrapf(err, "could not configure the seam carving")
+			}
+			if err := printSkinDebug(img, skin); err != nil {
+				return err
+			}
+			carver := seamcarve.NewCarver(opts)
+
+			img, err = carver.Shrink(img, noPixelsWidthToErase, noPixelsHeightToErase)
 			if err != nil {
 				return errors.Wrapf(err, "failed to process the erase of %vx%v pixels", noPixelsWidthToErase, noPixelsHeightToErase)
 			}
@@ -56,7 +92,7 @@ func IncreaseSizeImage() *cobra.Command {
 		Args: cobra.ExactArgs(3),
 		RunE: func(_ *cobra.Command, args []string) error {
 			imgPath := args[0]
-			img, err := meta.GetImageFromPath(imgPath)
+			img, err := meta.DecodeImageFromPath(imgPath, decodeOptions())
 			if err != nil {
 				return errors.Wrapf(err, "could not get an image obj from path '%v'", imgPath)
 			}
@@ -72,38 +108,19 @@ func IncreaseSizeImage() *cobra.Command {
 				return errors.Wrapf(err, "could not parse as integer arg received '%v'", args[1])
 			}
 
-			for noPixelsWidthToIncrease > 0 {
-				maxPixelsErase := img.Bounds().Dy() / *maxIncreaseDiv
-				pixelsToErase := noPixelsWidthToIncrease
-				if pixelsToErase > maxPixelsErase {
-					pixelsToErase = maxPixelsErase
-				}
-				img, err = processVerticalIncrease(img, pixelsToErase, *modeResize)
-				if err != nil {
-					return errors.Wrapf(err, "could not process the vertical increase of %v pixels on received image '%v'", args[1], imgPath)
-				}
-				noPixelsWidthToIncrease -= pixelsToErase
+			opts, skin, err := carverOptions(img.Bounds().Size(), img)
+			if err != nil {
+				return errors.Wrapf(err, "could not configure the seam carving")
 			}
-
-			img = meta.RotateClock(img)
-
-			for noPixelsHeightToIncrease > 0 {
-				maxPixelsErase := img.Bounds().Dx() / *maxIncreaseDiv
-
-				pixelsToErase := noPixelsHeightToIncrease
-				if pixelsToErase > maxPixelsErase {
-					pixelsToErase = maxPixelsErase
-				}
-				img, err = processVerticalIncrease(img, pixelsToErase, *modeResize)
-				if err != nil {
-					return errors.Wrapf(err, "could not process the vertical increase of %v pixels on the rotated received image '%v'", args[1], imgPath)
-				}
-				noPixelsHeightToIncrease -= pixelsToErase
+			if err := printSkinDebug(img, skin); err != nil {
+				return err
 			}
+			carver := seamcarve.NewCarver(opts)
 
-			img = meta.RotateClock(img)
-			img = meta.RotateClock(img)
-			img = meta.RotateClock(img)
+			img, err = carver.Grow(img, noPixelsWidthToIncrease, noPixelsHeightToIncrease)
+			if err != nil {
+				return errors.Wrapf(err, "could not process the increase of %vx%v pixels on received image '%v'", noPixelsWidthToIncrease, noPixelsHeightToIncrease, imgPath)
+			}
 
 			return printImage(img, initImg, *outputPath)
 		},
@@ -111,22 +128,109 @@ func IncreaseSizeImage() *cobra.Command {
 	return command
 }
 
-func proceedErase(img image.Image, noPixelsWidthToErase int, noPixelsHeightToErase int, mode string) (image.Image, error) {
-	img, err := proceedVerticalErase(img, noPixelsWidthToErase, mode)
+// carverOptions configures the carving from the flags for the processed image. The masks have the size of the input
+// image and are resized to the size of the processed image when the command changes it before carving. The skin
+// region is detected once for --protect-skin and --skin-debug, nil without them.
+func carverOptions(inputSize image.Point, workImg image.Image) (seamcarve.Options, *meta.Plane, error) {
+	workSize := workImg.Bounds().Size()
+	opts := seamcarve.Options{
+		MaxIncreaseDiv: *maxIncreaseDiv,
+		RestoreSize: *restoreSize,
+		Adaptive: *adaptiveErase,
+		TransparentAsZero: *transparentZero,
+	}
+
+	var err error
+	opts.RemoveMask, err = getInputMap(*removeMaskPath, "mask", inputSize, workSize, resize.NearestNeighbor)
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not process the vertical erase of %v pixels on received image", noPixelsWidthToErase)
+		return opts, nil, err
+	}
+	opts.ProtectMask, err = getInputMap(*protectMaskPath, "mask", inputSize, workSize, resize.NearestNeighbor)
+	if err != nil {
+		return opts, nil, err
+	}
+	// The importance is resized smoothly, its gray levels are soft priorities.
+	opts.Importance, err = getInputMap(*importancePath, "importance map", inputSize, workSize, resize.Bilinear)
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.ImportanceMode, err = seamcarve.ImportanceModeByName(*importanceMode)
+	if err != nil {
+		return opts, nil, err
+	}
+	if *importanceWeight <= 0 {
+		return opts, nil, errors.Errorf("the importance weight %v is not positive", *importanceWeight)
+	}
+	opts.ImportanceWeight = *importanceWeight
+
+	switch *modeResize {
+	case "dynamics":
+		// The default finder of the carver, which keeps its table between the seams.
+	case "forward":
+		opts.Forward = true
+	default:
+		opts.Finder = seamcarve.FinderByName(*modeResize)
 	}
 
-	img = meta.RotateClock(img)
+	opts.Order, err = seamcarve.OrderByName(*seamOrder)
+	if err != nil {
+		return opts, nil, err
+	}
+
+	if *verbose {
+		opts.Logf = func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, format + "\n", args...)
+		}
+	}
 
-	img, err = proceedVerticalErase(img, noPixelsHeightToErase, mode)
+	if *energyName != "" {
+		energy, err := meta.EnergyByName(*energyName)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.Energy = energy
+	}
+
+	if *saliencyWeight < 0 {
+		return opts, nil, errors.Errorf("the saliency weight %v is negative", *saliencyWeight)
+	}
+	opts.SaliencyWeight = *saliencyWeight
+	opts.Saliency, err = meta.SaliencyByName(*saliencyName)
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not process the orizontal erase of %v pixels on received image", noPixelsHeightToErase)
+		return opts, nil, err
+	}
+
+	var skin *meta.Plane
+	if *protectSkin || *skinDebugPath != "" {
+		if *skinRadius < 0 {
+			return opts, nil, errors.Errorf("the skin radius %v is negative", *skinRadius)
+		}
+		skin = meta.SkinDetector{MinFraction: 0.0005, Radius: *skinRadius}.Detect(workImg)
+		if *protectSkin {
+			opts.SkinRegion = skin
+		}
 	}
+	return opts, skin, nil
+}
 
-	img = meta.RotateClock(img)
-	img = meta.RotateClock(img)
-	img = meta.RotateClock(img)
+// getInputMap reads an image of the size of the input, like a mask, and resizes it with the interpolation to the
+// size of the processed image. The kind names the image in the errors.
+func getInputMap(path string, kind string, inputSize image.Point, workSize image.Point,
+	interpolation resize.InterpolationFunction) (image.Image, error) {
+	if path == "" {
+		return nil, nil
+	}
 
-	return img, nil
-}
\ No newline at end of file
+	inputMap, err := meta.DecodeImageFromPath(path, decodeOptions())
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get a %v obj from path '%v'", kind, path)
+	}
+	if inputMap.Bounds().Size() != inputSize {
+		return nil, errors.Errorf("%v '%v' has %v pixels, the input image has %v", kind, path, inputMap.Bounds().Size(), inputSize)
+	}
+
+	if workSize != inputSize {
+		inputMap = resize.Resize(uint(workSize.X), uint(workSize.Y), inputMap, interpolation)
+	}
+	return inputMap, nil
+}
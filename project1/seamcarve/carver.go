@@ -0,0 +1,209 @@
+// Package seamcarve implements the content-aware image resizing from
+// https://perso.crans.org/frenoy/matlab2012/seamcarving.pdf without any file or console side effects.
+package seamcarve
+
+import (
+	"github.com/pkg/errors"
+	"image"
+
+	"computer_vision/lib"
+)
+
+// Options configures a Carver, the zero value is usable.
+type Options struct {
+	// Energy used for ranking the pixels, the sobel magnitude if nil.
+	Energy meta.EnergyFunc
+	// Finder used for choosing one seam. If nil, the seams are found with dynamic programming on a table which is
+	// only updated around the removed seams.
+	Finder SeamFinder
+	// Forward selects the forward energy of Rubinstein et al. 2008 instead of the Finder. The Energy is then only
+	// the additional pixel energy of the paper, so it defaults to zero.
+	Forward bool
+	// TransparentAsZero gives no energy to the fully transparent pixels, so the seams go through the empty space.
+	TransparentAsZero bool
+	// Width and Height are the target size used by Resize. A value <= 0 keeps the dimension unchanged.
+	Width  int
+	Height int
+	// RemoveMask and ProtectMask are images of the same size as the processed image. The seams are forced through
+	// the white pixels of RemoveMask and kept out of the white pixels of ProtectMask.
+	RemoveMask  image.Image
+	ProtectMask image.Image
+	// Order of the vertical and horizontal seams when shrinking both dimensions, VerticalFirst by default.
+	Order Order
+	// Logf receives the verbose messages, nothing is reported if nil.
+	Logf func(format string, args ...interface{})
+	// Adaptive makes RemoveRegion choose for every seam the cheaper direction and stop when nothing is left of the
+	// region, instead of removing the seams of the narrower side of its bounding box.
+	Adaptive bool
+	// RestoreSize makes RemoveRegion insert as many seams as it removed, so the result keeps the size of the input.
+	RestoreSize bool
+	// No more than image_size/MaxIncreaseDiv seams are added in the same time when growing. Defaults to 2.
+	MaxIncreaseDiv int
+	// Saliency gives the importance of the pixels, added to their energy with the weight SaliencyWeight. The weight
+	// is relative to the strongest energy of the image, so with 1 the most salient pixels cost as much as the
+	// strongest edge. The spectral residual saliency if nil, nothing is added while the weight is 0.
+	Saliency       meta.SaliencyFunc
+	SaliencyWeight float64
+	// SkinRegion is a plane of the size of the processed image, like the one of meta.SkinDetector, whose non zero
+	// pixels are kept out of the seams like the white pixels of ProtectMask.
+	SkinRegion *meta.Plane
+	// Importance is a gray image of the size of the processed image, painted lighter where the content matters and
+	// darker where the seams should go, the middle gray changes nothing. ImportanceMode tells if it multiplies the
+	// energy or is added to it, ImportanceWeight is its strength and defaults to 1.
+	Importance       image.Image
+	ImportanceMode   ImportanceMode
+	ImportanceWeight float64
+}
+
+// Carver removes and inserts seams on images according to its options.
+type Carver struct {
+	opts Options
+}
+
+func NewCarver(opts Options) *Carver {
+	if opts.Energy == nil && opts.Forward {
+		opts.Energy = meta.ZeroEnergy
+	}
+	if opts.Energy == nil {
+		opts.Energy = meta.GradientEnergy{KernelX: meta.SobelX, KernelY: meta.SobelY}
+	}
+	if opts.TransparentAsZero {
+		opts.Energy = meta.TransparentAsZero(opts.Energy)
+	}
+	if opts.MaxIncreaseDiv <= 0 {
+		opts.MaxIncreaseDiv = 2
+	}
+	if opts.ImportanceWeight == 0 {
+		opts.ImportanceWeight = 1
+	}
+	if opts.Saliency == nil {
+		opts.Saliency = meta.SpectralResidualSaliency{Size: 64, Sigma: 3}
+	}
+	return &Carver{opts: opts}
+}
+
+func (c *Carver) logf(format string, args ...interface{}) {
+	if c.opts.Logf != nil {
+		c.opts.Logf(format, args...)
+	}
+}
+
+func (c *Carver) energy(img image.Image) *meta.Plane {
+	return c.opts.Energy.Energy(img)
+}
+
+// Resize shrinks or grows each dimension of the image until it reaches the target size of the options.
+func (c *Carver) Resize(img image.Image) (image.Image, error) {
+	dx, dy := 0, 0
+	if c.opts.Width > 0 {
+		dx = c.opts.Width - img.Bounds().Dx()
+	}
+	if c.opts.Height > 0 {
+		dy = c.opts.Height - img.Bounds().Dy()
+	}
+
+	img, err := c.Shrink(img, negativePart(dx), negativePart(dy))
+	if err != nil {
+		return nil, err
+	}
+	return c.Grow(img, positivePart(dx), positivePart(dy))
+}
+
+func negativePart(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return 0
+}
+
+func positivePart(x int) int {
+	if x > 0 {
+		return x
+	}
+	return 0
+}
+
+// Shrink removes noPixelsWidth vertical seams and noPixelsHeight horizontal seams from the image.
+func (c *Carver) Shrink(img image.Image, noPixelsWidth int, noPixelsHeight int) (image.Image, error) {
+	if noPixelsWidth < 0 || noPixelsHeight < 0 {
+		return nil, errors.Errorf("could not shrink with a negative number of pixels %vx%v", noPixelsWidth, noPixelsHeight)
+	}
+	if noPixelsWidth >= img.Bounds().Dx() || noPixelsHeight >= img.Bounds().Dy() {
+		return nil, errors.Errorf("could not shrink %vx%v pixels from an image of %vx%v",
+			noPixelsWidth, noPixelsHeight, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	bias, err := c.energyBias(img)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.opts.Order == Optimal {
+		img, order := c.shrinkOptimal(img, bias, noPixelsWidth, noPixelsHeight)
+		c.logf("seam order %v: %v", c.opts.Order, describeOrder(order))
+		return dense(img), nil
+	}
+
+	order := seamOrder(c.opts.Order, noPixelsWidth, noPixelsHeight)
+	c.logf("seam order %v: %v", c.opts.Order, describeOrder(order))
+	return dense(c.shrinkInOrder(img, bias, order)), nil
+}
+
+// Grow inserts noPixelsWidth vertical seams and noPixelsHeight horizontal seams in the image.
+func (c *Carver) Grow(img image.Image, noPixelsWidth int, noPixelsHeight int) (image.Image, error) {
+	if noPixelsWidth < 0 || noPixelsHeight < 0 {
+		return nil, errors.Errorf("could not grow with a negative number of pixels %vx%v", noPixelsWidth, noPixelsHeight)
+	}
+
+	bias, err := c.energyBias(img)
+	if err != nil {
+		return nil, err
+	}
+
+	img, bias, err = c.growVertical(img, bias, noPixelsWidth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not process the vertical increase of %v pixels", noPixelsWidth)
+	}
+
+	if noPixelsHeight == 0 {
+		return img, nil
+	}
+
+	// The horizontal seams are the vertical seams of the transposed view, which copies nothing.
+	img, _, err = c.growVertical(meta.Transposed(img), bias.transpose(), noPixelsHeight)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not process the horizontal increase of %v pixels", noPixelsHeight)
+	}
+
+	return dense(meta.Transposed(img)), nil
+}
+
+// dense copies the transposed views before the images leave the carver, so the callers get images whose pixels are
+// read directly. A horizontal pass thus copies the image twice: newCarving makes a dense copy of the view when it
+// starts, and dense copies the result back.
+func dense(img image.Image) image.Image {
+	if _, ok := img.(*meta.TransposedImage); ok {
+		return meta.CopyImage(img)
+	}
+	return img
+}
+
+func (c *Carver) growVertical(img image.Image, bias *pixelBias, noPixelsToIncrease int) (image.Image, *pixelBias, error) {
+	var err error
+	for noPixelsToIncrease > 0 {
+		maxPixelsIncrease := img.Bounds().Dx() / c.opts.MaxIncreaseDiv
+		if maxPixelsIncrease == 0 {
+			return nil, nil, errors.Errorf("image of width %v is too narrow for inserting seams", img.Bounds().Dx())
+		}
+		pixelsToIncrease := noPixelsToIncrease
+		if pixelsToIncrease > maxPixelsIncrease {
+			pixelsToIncrease = maxPixelsIncrease
+		}
+		img, bias, err = c.verticalIncrease(img, bias, pixelsToIncrease)
+		if err != nil {
+			return nil, nil, err
+		}
+		noPixelsToIncrease -= pixelsToIncrease
+	}
+	return img, bias, nil
+}
@@ -0,0 +1,258 @@
+package seamcarve
+
+import (
+	"image"
+	"math"
+
+	"computer_vision/lib"
+)
+
+// Number of lines on which the energy is computed again in one call of the energy function.
+const bandLines = 32
+
+// carving is an image in the middle of the seam removal together with the planes shifted with it.
+type carving struct {
+	img       image.Image
+	magnitude *meta.Plane
+	// bias changes the energy of every pixel, so the forced values of the masks survive when the energy is computed
+	// again. Nil when there is no mask nor map.
+	bias *pixelBias
+	// gray is the intensity of the pixels, only kept for the forward energy.
+	gray *meta.Plane
+
+	// dyn and frm are the table of the dynamic programming kept between two seams, frm is the offset of the previous
+	// pixel of the seam, stored line after line like the planes. Nil until the first seam is found and when a custom
+	// finder is used.
+	dyn *meta.Plane
+	frm []int8
+	// For every line, the interval of columns whose cost changed since the table was computed.
+	dirtyLo []int
+	dirtyHi []int
+}
+
+func (c *Carver) newCarving(img image.Image, bias *pixelBias) *carving {
+	// The seams are removed from copies made by the lib, so the energy is always computed on the same pixels.
+	img = meta.CopyImage(img)
+	cv := &carving{img: img, magnitude: c.energy(img), bias: bias}
+	bias.applyAll(cv.magnitude)
+	if c.opts.Forward {
+		cv.gray = meta.GetGrayImage(img)
+	}
+	return cv
+}
+
+func (c *Carver) findSeam(cv *carving) []int {
+	if !c.opts.Forward && c.opts.Finder != nil {
+		return c.opts.Finder(cv.magnitude)
+	}
+
+	c.updateTable(cv)
+
+	width := cv.dyn.Width
+	height := cv.dyn.Height
+
+	lastP := 0
+	lastLine := cv.dyn.Row(height - 1)
+	for x := 1; x < width; x++ {
+		if lastLine[x] < lastLine[lastP] {
+			lastP = x
+		}
+	}
+
+	vertical := make([]int, height)
+	vertical[height - 1] = lastP
+	for y := height - 1; y > 0; y-- {
+		lastP += int(cv.frm[y * width + lastP])
+		vertical[y - 1] = lastP
+	}
+	return vertical
+}
+
+// removeSeam deletes the seam from the image and all its planes, then computes again the energy of the pixels
+// which had the seam in their neighbourhood.
+func (c *Carver) removeSeam(cv *carving, vertical []int) {
+	cv.img, cv.magnitude = deleteVertical(vertical, cv.img, cv.magnitude)
+	cv.bias = cv.bias.deleteVertical(vertical)
+	if cv.gray != nil {
+		cv.gray = deletePlaneVertical(vertical, cv.gray)
+	}
+	if cv.dyn != nil {
+		cv.frm = deleteOffsetsVertical(vertical, cv.frm, cv.dyn.Width)
+		cv.dyn = deletePlaneVertical(vertical, cv.dyn)
+	}
+
+	local, isLocal := c.opts.Energy.(meta.LocalEnergyFunc)
+	subImg, canCrop := cv.img.(interface{ SubImage(r image.Rectangle) image.Image })
+	if !isLocal || !canCrop {
+		cv.magnitude = c.energy(cv.img)
+		cv.bias.applyAll(cv.magnitude)
+		cv.dyn = nil
+		cv.frm = nil
+		return
+	}
+
+	width := cv.magnitude.Width
+	height := cv.magnitude.Height
+	radius := local.Radius()
+
+	// The pixels whose window contained a pixel of the seam, with a margin for the neighbours joined by the removal
+	// which change the transitions of the dynamic programming and the forward costs.
+	lo := make([]int, height)
+	hi := make([]int, height)
+	for y := 0; y < height; y++ {
+		lo[y] = width
+		hi[y] = -1
+		for i := y - radius - 1; i <= y + radius + 1; i++ {
+			if i < 0 || i >= height {
+				continue
+			}
+			if vertical[i] - radius - 2 < lo[y] {
+				lo[y] = vertical[i] - radius - 2
+			}
+			if vertical[i] + radius + 1 > hi[y] {
+				hi[y] = vertical[i] + radius + 1
+			}
+		}
+		lo[y] = meta.ClampInt(lo[y], 0, width - 1)
+		hi[y] = meta.ClampInt(hi[y], 0, width - 1)
+	}
+
+	for y0 := 0; y0 < height; y0 += bandLines {
+		y1 := y0 + bandLines
+		if y1 > height {
+			y1 = height
+		}
+
+		left, right := width, -1
+		for y := y0; y < y1; y++ {
+			if lo[y] < left {
+				left = lo[y]
+			}
+			if hi[y] > right {
+				right = hi[y]
+			}
+		}
+
+		crop := image.Rect(left - radius, y0 - radius, right + radius + 1, y1 + radius).Intersect(cv.img.Bounds())
+		energy := local.Energy(subImg.SubImage(crop))
+		for y := y0; y < y1; y++ {
+			row := cv.magnitude.Row(y)
+			energyRow := energy.Row(y - crop.Min.Y)
+			copy(row[lo[y]:hi[y] + 1], energyRow[lo[y] - crop.Min.X:])
+			cv.bias.apply(row, y, lo[y], hi[y])
+		}
+	}
+
+	if cv.dyn != nil {
+		for y := 0; y < height; y++ {
+			if lo[y] < cv.dirtyLo[y] {
+				cv.dirtyLo[y] = lo[y]
+			}
+			if hi[y] > cv.dirtyHi[y] {
+				cv.dirtyHi[y] = hi[y]
+			}
+		}
+	}
+}
+
+// updateTable builds the table of the dynamic programming, or only computes again the dirty cells and the cells
+// below them whose cost really changed.
+func (c *Carver) updateTable(cv *carving) {
+	width := cv.magnitude.Width
+	height := cv.magnitude.Height
+
+	if cv.dyn == nil {
+		cv.dyn = meta.NewPlane(width, height)
+		cv.frm = make([]int8, width * height)
+		cv.dirtyLo = make([]int, height)
+		cv.dirtyHi = make([]int, height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				cv.dyn.Pix[y * width + x], cv.frm[y * width + x] = c.tableCell(cv, x, y)
+			}
+			cv.dirtyLo[y] = width
+			cv.dirtyHi[y] = -1
+		}
+		return
+	}
+
+	// Interval of the cells which changed on the previous line.
+	changedLo, changedHi := width, -1
+	for y := 0; y < height; y++ {
+		lo, hi := cv.dirtyLo[y], cv.dirtyHi[y]
+		if changedLo <= changedHi {
+			if changedLo - 1 < lo {
+				lo = changedLo - 1
+			}
+			if changedHi + 1 > hi {
+				hi = changedHi + 1
+			}
+		}
+		lo = meta.ClampInt(lo, 0, width - 1)
+
+		changedLo, changedHi = width, -1
+		for x := lo; x <= hi && x < width; x++ {
+			val, from := c.tableCell(cv, x, y)
+			if val == cv.dyn.Pix[y * width + x] && from == cv.frm[y * width + x] {
+				continue
+			}
+			cv.dyn.Pix[y * width + x], cv.frm[y * width + x] = val, from
+			if x < changedLo {
+				changedLo = x
+			}
+			changedHi = x
+		}
+
+		cv.dirtyLo[y] = width
+		cv.dirtyHi[y] = -1
+	}
+}
+
+// tableCell returns the minimum cost of a seam ending in the pixel and the offset of its previous pixel.
+func (c *Carver) tableCell(cv *carving, x int, y int) (float64, int8) {
+	width := cv.magnitude.Width
+
+	var costUp, costLeft, costRight float64
+	if c.opts.Forward {
+		// Neighbours out of the image are replaced by the border pixel.
+		left := cv.gray.At(meta.ClampInt(x - 1, 0, width - 1), y)
+		right := cv.gray.At(meta.ClampInt(x + 1, 0, width - 1), y)
+		costUp = math.Abs(right - left)
+		if y > 0 {
+			costLeft = costUp + math.Abs(cv.gray.At(x, y - 1) - left)
+			costRight = costUp + math.Abs(cv.gray.At(x, y - 1) - right)
+		}
+		// The forward costs are the energy of the mode, so the importance scales them like the pixel energy.
+		scale := cv.bias.scaleAt(x, y)
+		costUp, costLeft, costRight = costUp * scale, costLeft * scale, costRight * scale
+	}
+
+	if y == 0 {
+		return cv.magnitude.At(x, 0) + costUp, 0
+	}
+
+	prev := cv.dyn.Row(y - 1)
+	best := prev[x] + costUp
+	from := int8(0)
+	if x != 0 && prev[x - 1] + costLeft < best {
+		best = prev[x - 1] + costLeft
+		from = -1
+	}
+	if x != width - 1 && prev[x + 1] + costRight < best {
+		best = prev[x + 1] + costRight
+		from = 1
+	}
+	return best + cv.magnitude.At(x, y), from
+}
+
+// deleteOffsetsVertical removes the seam from the offsets of a table of width columns.
+func deleteOffsetsVertical(vertical []int, offsets []int8, width int) []int8 {
+	ret := make([]int8, (width - 1) * len(vertical))
+	for line, indexDel := range vertical {
+		src := offsets[line * width:(line + 1) * width]
+		dst := ret[line * (width - 1):(line + 1) * (width - 1)]
+		copy(dst, src[:indexDel])
+		copy(dst[indexDel:], src[indexDel + 1:])
+	}
+	return ret
+}
@@ -0,0 +1,146 @@
+package seamcarve
+
+import (
+	"github.com/pkg/errors"
+	"image"
+
+	"computer_vision/lib"
+)
+
+// RemoveRegion erases the content inside the polygons and the white pixels of the RemoveMask of the options by
+// removing seams forced to pass through them. The polygons are filled with the given rule, so they may be concave and
+// the ones inside others may be holes. There may be no polygon when there is a RemoveMask.
+// The seams are vertical when the region is narrower than taller and horizontal otherwise, or chosen for every seam
+// with Adaptive. With RestoreSize, the same number of seams is inserted afterwards in the same directions.
+func (c *Carver) RemoveRegion(img image.Image, polygons []meta.Polygon, rule meta.FillRule) (image.Image, error) {
+	for _, polygon := range polygons {
+		if len(polygon) < 3 {
+			return nil, errors.Errorf("a polygon needs at least 3 points, received %v", len(polygon))
+		}
+	}
+	if len(polygons) == 0 && c.opts.RemoveMask == nil {
+		return nil, errors.New("no polygon and no remove mask to define the region")
+	}
+
+	bias, err := c.energyBias(img)
+	if err != nil {
+		return nil, err
+	}
+	if bias == nil {
+		bias = &pixelBias{offset: meta.NewPlane(img.Bounds().Dx(), img.Bounds().Dy())}
+	}
+
+	if len(polygons) > 0 {
+		addRegionBias(bias.offset, meta.Rasterize(polygons, rule, bias.offset.Width, bias.offset.Height), removeEnergy)
+	}
+
+	// The bounding box of the pixels to remove.
+	left, right, up, down := bias.offset.Width, -1, bias.offset.Height, -1
+	for y := 0; y < bias.offset.Height; y++ {
+		for x, val := range bias.offset.Row(y) {
+			if val >= removeEnergy / 2 {
+				continue
+			}
+			if x < left {
+				left = x
+			}
+			if x > right {
+				right = x
+			}
+			if y < up {
+				up = y
+			}
+			if y > down {
+				down = y
+			}
+		}
+	}
+	if right < 0 {
+		return nil, errors.New("the region to remove is empty")
+	}
+
+	if c.opts.Adaptive {
+		return c.removeAdaptive(img, bias)
+	}
+
+	if down - up < right - left {
+		img, bias = c.verticalErase(meta.Transposed(img), bias.transpose(), down - up + 1)
+		return c.restoreSize(meta.Transposed(img), bias.transpose(), 0, down - up + 1)
+	}
+
+	img, bias = c.verticalErase(img, bias, right - left + 1)
+	return c.restoreSize(img, bias, right - left + 1, 0)
+}
+
+// removeAdaptive removes at every step the cheaper of the best vertical and the best horizontal seam, until no pixel
+// of the region is left.
+func (c *Carver) removeAdaptive(img image.Image, bias *pixelBias) (image.Image, error) {
+	noPixelsWidth, noPixelsHeight := 0, 0
+	for left := regionSize(bias); left > 0; {
+		if img.Bounds().Dx() == 1 || img.Bounds().Dy() == 1 {
+			return nil, errors.New("the image became a line before the region was removed")
+		}
+
+		verticalCost, verticalImg, verticalBias := c.cheapestSeam(img, bias, true)
+		horizontalCost, horizontalImg, horizontalBias := c.cheapestSeam(img, bias, false)
+		if verticalCost <= horizontalCost {
+			img, bias = verticalImg, verticalBias
+			noPixelsWidth++
+		} else {
+			img, bias = horizontalImg, horizontalBias
+			noPixelsHeight++
+		}
+
+		// The protect mask may keep all the seams away from what is left of the region.
+		nowLeft := regionSize(bias)
+		if nowLeft == left {
+			return nil, errors.Errorf("no seam passes through the %v pixels left of the region", left)
+		}
+		left = nowLeft
+	}
+
+	c.logf("adaptive removal: %v vertical and %v horizontal seams", noPixelsWidth, noPixelsHeight)
+	return c.restoreSize(img, bias, noPixelsWidth, noPixelsHeight)
+}
+
+// regionSize counts the pixels which are still to be removed.
+func regionSize(bias *pixelBias) int {
+	count := 0
+	for y := 0; y < bias.offset.Height; y++ {
+		for _, val := range bias.offset.Row(y) {
+			if val < removeEnergy / 2 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// restoreSize inserts back the removed seams when RestoreSize is set, the vertical ones first.
+func (c *Carver) restoreSize(img image.Image, bias *pixelBias, noPixelsWidth int, noPixelsHeight int) (image.Image, error) {
+	if !c.opts.RestoreSize {
+		return dense(img), nil
+	}
+
+	// The pixels of the region which survived must not attract the new seams, the other changes of the energy are
+	// kept.
+	for i, val := range bias.offset.Pix {
+		if val < removeEnergy / 2 {
+			bias.offset.Pix[i] = val - removeEnergy
+		}
+	}
+
+	img, bias, err := c.growVertical(img, bias, noPixelsWidth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not insert back %v vertical seams", noPixelsWidth)
+	}
+	if noPixelsHeight == 0 {
+		return dense(img), nil
+	}
+
+	img, _, err = c.growVertical(meta.Transposed(img), bias.transpose(), noPixelsHeight)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not insert back %v horizontal seams", noPixelsHeight)
+	}
+	return dense(meta.Transposed(img)), nil
+}
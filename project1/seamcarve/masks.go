@@ -0,0 +1,237 @@
+package seamcarve
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"image"
+	"math"
+	"strings"
+
+	"computer_vision/lib"
+)
+
+const (
+	// The energy added to the pixels which must be removed, low enough to beat any path through normal pixels.
+	removeEnergy = -10000000
+	// The energy added to the pixels which must be kept.
+	protectEnergy = 10000000
+)
+
+// ImportanceMode is the way the importance map changes the energy of the pixels.
+type ImportanceMode int
+
+const (
+	// ImportanceMultiply multiplies the energy by 1 + weight*(2*importance - 1), at least 0.
+	ImportanceMultiply ImportanceMode = iota
+	// ImportanceAdd adds weight*(2*importance - 1) times the strongest energy of the image.
+	ImportanceAdd
+)
+
+var importanceModeNames = []string{"multiply", "add"}
+
+// ImportanceModeByName parses the cli names of the importance modes.
+func ImportanceModeByName(name string) (ImportanceMode, error) {
+	for i, modeName := range importanceModeNames {
+		if modeName == name {
+			return ImportanceMode(i), nil
+		}
+	}
+	return 0, errors.Errorf("unknown importance mode '%v', expected one of %v", name, strings.Join(importanceModeNames, ", "))
+}
+
+func (m ImportanceMode) String() string {
+	if m < 0 || int(m) >= len(importanceModeNames) {
+		return fmt.Sprintf("ImportanceMode(%d)", int(m))
+	}
+	return importanceModeNames[m]
+}
+
+// pixelBias changes the energy of the pixels and is shifted with them by the seams, so the masks and the maps of the
+// options survive when the energy is computed again. A nil bias changes nothing.
+type pixelBias struct {
+	// scale multiplies the energy of every pixel, nil without importance map in ImportanceMultiply mode.
+	scale *meta.Plane
+	// offset is added to the scaled energy.
+	offset *meta.Plane
+}
+
+// apply changes the energy of the pixels lo to hi of the line y.
+func (b *pixelBias) apply(row []float64, y int, lo int, hi int) {
+	if b == nil {
+		return
+	}
+	if b.scale != nil {
+		for x, val := range b.scale.Row(y)[lo:hi + 1] {
+			row[lo + x] *= val
+		}
+	}
+	for x, val := range b.offset.Row(y)[lo:hi + 1] {
+		row[lo + x] += val
+	}
+}
+
+// scaleAt returns the factor of the energy of the pixel, 1 without scale.
+func (b *pixelBias) scaleAt(x int, y int) float64 {
+	if b == nil || b.scale == nil {
+		return 1
+	}
+	return b.scale.At(x, y)
+}
+
+// applyAll changes the energy of all the pixels.
+func (b *pixelBias) applyAll(magnitude *meta.Plane) {
+	for y := 0; y < magnitude.Height; y++ {
+		b.apply(magnitude.Row(y), y, 0, magnitude.Width - 1)
+	}
+}
+
+// mapPlanes returns the bias with f applied on each of its planes.
+func (b *pixelBias) mapPlanes(f func(*meta.Plane) *meta.Plane) *pixelBias {
+	if b == nil {
+		return nil
+	}
+	ret := &pixelBias{offset: f(b.offset)}
+	if b.scale != nil {
+		ret.scale = f(b.scale)
+	}
+	return ret
+}
+
+func (b *pixelBias) transpose() *pixelBias {
+	return b.mapPlanes((*meta.Plane).Transpose)
+}
+
+func (b *pixelBias) deleteVertical(vertical []int) *pixelBias {
+	return b.mapPlanes(func(p *meta.Plane) *meta.Plane { return deletePlaneVertical(vertical, p) })
+}
+
+func (b *pixelBias) increaseVertical(vertical []int) *pixelBias {
+	return b.mapPlanes(func(p *meta.Plane) *meta.Plane { return increasePlaneVertical(p, vertical) })
+}
+
+// energyBias returns the changes of the energy of the pixels of the image by the masks, the saliency, the skin
+// detection and the importance map of the options, nil without them.
+func (c *Carver) energyBias(img image.Image) (*pixelBias, error) {
+	if c.opts.RemoveMask == nil && c.opts.ProtectMask == nil && c.opts.SaliencyWeight == 0 && c.opts.SkinRegion == nil &&
+		c.opts.Importance == nil {
+		return nil, nil
+	}
+
+	bias := &pixelBias{offset: meta.NewPlane(img.Bounds().Dx(), img.Bounds().Dy())}
+	if c.opts.SaliencyWeight != 0 {
+		c.addSaliencyBias(bias.offset, img)
+	}
+	if c.opts.Importance != nil {
+		if err := c.addImportanceBias(bias, img); err != nil {
+			return nil, errors.Wrapf(err, "could not use the importance map")
+		}
+	}
+
+	if err := addMaskBias(bias.offset, c.opts.RemoveMask, removeEnergy); err != nil {
+		return nil, errors.Wrapf(err, "could not use the remove mask")
+	}
+	if err := addMaskBias(bias.offset, c.opts.ProtectMask, protectEnergy); err != nil {
+		return nil, errors.Wrapf(err, "could not use the protect mask")
+	}
+	if c.opts.SkinRegion != nil {
+		skin := c.opts.SkinRegion
+		if skin.Width != bias.offset.Width || skin.Height != bias.offset.Height {
+			return nil, errors.Errorf("skin region of %vx%v pixels for an image of %vx%v",
+				skin.Width, skin.Height, bias.offset.Width, bias.offset.Height)
+		}
+		c.logf("skin protected on %v pixels", addRegionBias(bias.offset, skin, protectEnergy))
+	}
+	return bias, nil
+}
+
+// energyScale is the strongest energy of the image, which gives its units to the maps. The zero energy of the
+// forward mode has no units, so the sobel magnitude gives them.
+func (c *Carver) energyScale(img image.Image) float64 {
+	scale := c.energy(img).Max()
+	if scale == 0 {
+		scale = meta.GradientEnergy{KernelX: meta.SobelX, KernelY: meta.SobelY}.Energy(img).Max()
+	}
+	return scale
+}
+
+// addSaliencyBias adds the weighted saliency of the pixels, in the units of the strongest energy of the image.
+func (c *Carver) addSaliencyBias(bias *meta.Plane, img image.Image) {
+	scale := c.energyScale(img)
+	saliency := c.opts.Saliency.Saliency(img)
+	for i, val := range saliency.Pix {
+		bias.Pix[i] += c.opts.SaliencyWeight * scale * val
+	}
+	c.logf("saliency added with a weight of %v on an energy scale of %.4g", c.opts.SaliencyWeight, scale)
+}
+
+// addImportanceBias turns the gray levels of the importance map into a scale or an offset of the energy, centered on
+// the middle gray.
+func (c *Carver) addImportanceBias(bias *pixelBias, img image.Image) error {
+	importance := c.opts.Importance
+	if importance.Bounds().Dx() != bias.offset.Width || importance.Bounds().Dy() != bias.offset.Height {
+		return errors.Errorf("map of %vx%v pixels for an image of %vx%v",
+			importance.Bounds().Dx(), importance.Bounds().Dy(), bias.offset.Width, bias.offset.Height)
+	}
+
+	centered := meta.GetGrayImage(importance)
+	for i, val := range centered.Pix {
+		centered.Pix[i] = c.opts.ImportanceWeight * (2 * val / 65535 - 1)
+	}
+
+	if c.opts.ImportanceMode == ImportanceAdd {
+		scale := c.energyScale(img)
+		for i, val := range centered.Pix {
+			bias.offset.Pix[i] += scale * val
+		}
+		c.logf("importance added with a weight of %v on an energy scale of %.4g", c.opts.ImportanceWeight, scale)
+		return nil
+	}
+
+	for i, val := range centered.Pix {
+		centered.Pix[i] = math.Max(0, 1 + val)
+	}
+	bias.scale = centered
+	c.logf("energy multiplied by the importance with a weight of %v", c.opts.ImportanceWeight)
+	return nil
+}
+
+func addMaskBias(bias *meta.Plane, mask image.Image, energy float64) error {
+	if mask == nil {
+		return nil
+	}
+	if mask.Bounds().Dx() != bias.Width || mask.Bounds().Dy() != bias.Height {
+		return errors.Errorf("mask of %vx%v pixels for an image of %vx%v",
+			mask.Bounds().Dx(), mask.Bounds().Dy(), bias.Width, bias.Height)
+	}
+
+	addRegionBias(bias, meta.GetMask(mask), energy)
+	return nil
+}
+
+// addRegionBias adds the energy to the pixels of the region, the non zero values of its plane, and returns their
+// number.
+func addRegionBias(bias *meta.Plane, region *meta.Plane, energy float64) int {
+	count := 0
+	for y := 0; y < region.Height; y++ {
+		line := bias.Row(y)
+		for x, val := range region.Row(y) {
+			if val != 0 {
+				line[x] += energy
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// increasePlaneVertical inserts a copy of the pixels of the seam on the left of them.
+func increasePlaneVertical(plane *meta.Plane, vertical []int) *meta.Plane {
+	ret := meta.NewPlane(plane.Width + 1, plane.Height)
+	for line, indexAdd := range vertical {
+		src := plane.Row(line)
+		dst := ret.Row(line)
+		copy(dst, src[:indexAdd + 1])
+		copy(dst[indexAdd + 1:], src[indexAdd:])
+	}
+	return ret
+}
@@ -0,0 +1,51 @@
+package seamcarve
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestImportanceRemovesBlackFirst checks that the black stripe of the importance map is removed before any other
+// column of a noise image, in both modes and with both the backward and the forward energy.
+func TestImportanceRemovesBlackFirst(t *testing.T) {
+	const width, height, stripeLo, stripeHi = 40, 20, 10, 15
+	img := noiseImage(width, height, 2)
+
+	importance := image.NewGray(img.Bounds())
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < stripeLo || x >= stripeHi {
+				importance.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	for _, mode := range []ImportanceMode{ImportanceMultiply, ImportanceAdd} {
+		for _, forward := range []bool{false, true} {
+			carver := NewCarver(Options{Importance: importance, ImportanceMode: mode, Forward: forward})
+			result, err := carver.Shrink(img, stripeHi - stripeLo, 0)
+			if err != nil {
+				t.Fatalf("%v, forward %v: %v", mode, forward, err)
+			}
+
+			if result.Bounds().Dx() != width - (stripeHi - stripeLo) || result.Bounds().Dy() != height {
+				t.Fatalf("%v, forward %v: result of %v", mode, forward, result.Bounds().Size())
+			}
+			for y := 0; y < height; y++ {
+				for x := 0; x < result.Bounds().Dx(); x++ {
+					srcX := x
+					if x >= stripeLo {
+						srcX += stripeHi - stripeLo
+					}
+					r, g, b, a := result.At(x, y).RGBA()
+					sr, sg, sb, sa := img.At(srcX, y).RGBA()
+					if r != sr || g != sg || b != sb || a != sa {
+						t.Fatalf("%v, forward %v: pixel (%v, %v) is not the pixel (%v, %v) of the input",
+							mode, forward, x, y, srcX, y)
+					}
+				}
+			}
+		}
+	}
+}
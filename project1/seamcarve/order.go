@@ -0,0 +1,196 @@
+package seamcarve
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"image"
+	"math"
+	"strings"
+
+	"computer_vision/lib"
+)
+
+// Order is the interleaving of the vertical and horizontal seams when both dimensions are reduced.
+type Order int
+
+const (
+	// VerticalFirst removes all the vertical seams, then all the horizontal ones.
+	VerticalFirst Order = iota
+	// HorizontalFirst removes all the horizontal seams, then all the vertical ones.
+	HorizontalFirst
+	// Alternate removes one vertical and one horizontal seam until one of the dimensions is done.
+	Alternate
+	// Optimal chooses the order with the minimum total cost with the transport map of the seam carving paper.
+	// It finds (width+1)*(height+1) seams on full images, so it is much slower than the other orders.
+	Optimal
+)
+
+var orderNames = []string{"vertical-first", "horizontal-first", "alternate", "optimal"}
+
+// OrderByName parses the cli names of the orders.
+func OrderByName(name string) (Order, error) {
+	for i, orderName := range orderNames {
+		if orderName == name {
+			return Order(i), nil
+		}
+	}
+	return 0, errors.Errorf("unknown order '%v', expected one of %v", name, strings.Join(orderNames, ", "))
+}
+
+func (o Order) String() string {
+	if o < 0 || int(o) >= len(orderNames) {
+		return fmt.Sprintf("Order(%d)", int(o))
+	}
+	return orderNames[o]
+}
+
+// seamOrder returns the direction of every seam to remove, true for a vertical seam.
+func seamOrder(order Order, noPixelsWidth int, noPixelsHeight int) []bool {
+	var ret []bool
+	switch order {
+	case HorizontalFirst:
+		ret = appendSeams(ret, false, noPixelsHeight)
+		ret = appendSeams(ret, true, noPixelsWidth)
+	case Alternate:
+		for noPixelsWidth > 0 || noPixelsHeight > 0 {
+			if noPixelsWidth > 0 {
+				ret = append(ret, true)
+				noPixelsWidth--
+			}
+			if noPixelsHeight > 0 {
+				ret = append(ret, false)
+				noPixelsHeight--
+			}
+		}
+	default:
+		ret = appendSeams(ret, true, noPixelsWidth)
+		ret = appendSeams(ret, false, noPixelsHeight)
+	}
+	return ret
+}
+
+func appendSeams(order []bool, vertical bool, count int) []bool {
+	for i := 0; i < count; i++ {
+		order = append(order, vertical)
+	}
+	return order
+}
+
+// describeOrder compresses the order as runs of seams in the same direction, like '12V 3H 5V'.
+func describeOrder(order []bool) string {
+	var runs []string
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && order[j] == order[i] {
+			j++
+		}
+		direction := "H"
+		if order[i] {
+			direction = "V"
+		}
+		runs = append(runs, fmt.Sprintf("%v%v", j - i, direction))
+		i = j
+	}
+	if len(runs) == 0 {
+		return "no seams"
+	}
+	return strings.Join(runs, " ")
+}
+
+// shrinkInOrder removes the seams in the given order, the consecutive seams in the same direction are removed on
+// the same orientation of the image. The horizontal seams are removed from the transposed view of the image.
+func (c *Carver) shrinkInOrder(img image.Image, bias *pixelBias, order []bool) image.Image {
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && order[j] == order[i] {
+			j++
+		}
+		if order[i] {
+			img, bias = c.verticalErase(img, bias, j - i)
+		} else {
+			img, bias = c.verticalErase(meta.Transposed(img), bias.transpose(), j - i)
+			img, bias = meta.Transposed(img), bias.transpose()
+		}
+		i = j
+	}
+	return img
+}
+
+// shrinkOptimal fills the transport map: the cell [v][h] is the minimum cost of removing v vertical and h
+// horizontal seams, reached either from [v-1][h] with a vertical seam or from [v][h-1] with a horizontal one.
+// Only the images of the previous line of the map are kept.
+func (c *Carver) shrinkOptimal(img image.Image, bias *pixelBias, noPixelsWidth int, noPixelsHeight int) (image.Image, []bool) {
+	prevImgs := make([]image.Image, noPixelsHeight + 1)
+	prevBias := make([]*pixelBias, noPixelsHeight + 1)
+	prevCost := make([]float64, noPixelsHeight + 1)
+	// Direction of the last seam on the best path to each cell, true for vertical.
+	choice := make([][]bool, noPixelsWidth + 1)
+
+	for v := 0; v <= noPixelsWidth; v++ {
+		curImgs := make([]image.Image, noPixelsHeight + 1)
+		curBias := make([]*pixelBias, noPixelsHeight + 1)
+		curCost := make([]float64, noPixelsHeight + 1)
+		choice[v] = make([]bool, noPixelsHeight + 1)
+
+		for h := 0; h <= noPixelsHeight; h++ {
+			if v == 0 && h == 0 {
+				curImgs[0], curBias[0] = img, bias
+				continue
+			}
+
+			curCost[h] = math.Inf(1)
+			if v > 0 {
+				seamCost, nextImg, nextBias := c.cheapestSeam(prevImgs[h], prevBias[h], true)
+				curCost[h], curImgs[h], curBias[h], choice[v][h] = prevCost[h] + seamCost, nextImg, nextBias, true
+			}
+			if h > 0 {
+				seamCost, nextImg, nextBias := c.cheapestSeam(curImgs[h - 1], curBias[h - 1], false)
+				if curCost[h - 1] + seamCost < curCost[h] {
+					curCost[h], curImgs[h], curBias[h], choice[v][h] = curCost[h - 1] + seamCost, nextImg, nextBias, false
+				}
+			}
+		}
+
+		prevImgs = curImgs
+		prevBias = curBias
+		prevCost = curCost
+	}
+
+	order := make([]bool, noPixelsWidth + noPixelsHeight)
+	for v, h := noPixelsWidth, noPixelsHeight; v > 0 || h > 0; {
+		order[v + h - 1] = choice[v][h]
+		if choice[v][h] {
+			v--
+		} else {
+			h--
+		}
+	}
+	return prevImgs[noPixelsHeight], order
+}
+
+// cheapestSeam removes the seam with the minimum cost in the given direction and returns its cost.
+func (c *Carver) cheapestSeam(img image.Image, bias *pixelBias, vertical bool) (float64, image.Image, *pixelBias) {
+	if !vertical {
+		img, bias = meta.Transposed(img), bias.transpose()
+	}
+
+	cv := c.newCarving(img, bias)
+	seam := c.findSeam(cv)
+
+	var cost float64
+	if cv.dyn != nil {
+		cost = cv.dyn.At(seam[len(seam) - 1], len(seam) - 1)
+	} else {
+		for y, x := range seam {
+			cost += cv.magnitude.At(x, y)
+		}
+	}
+	// The carving is not used again, so only the image is needed.
+	img, _ = deleteVertical(seam, cv.img, cv.magnitude)
+	bias = bias.deleteVertical(seam)
+
+	if !vertical {
+		return cost, meta.Transposed(img), bias.transpose()
+	}
+	return cost, img, bias
+}
@@ -0,0 +1,273 @@
+package seamcarve
+
+import (
+	"github.com/pkg/errors"
+	"image"
+	"image/color"
+	"math/rand"
+
+	"computer_vision/lib"
+)
+
+// SeamFinder returns, for each line of the magnitude, the column of the pixel which belongs to the seam.
+type SeamFinder func(magnitude *meta.Plane) []int
+
+// FinderByName maps the cli mode names on seam finders.
+// 'dynamics' and 'greedy' are recognized, anything else is a random finder.
+func FinderByName(mode string) SeamFinder {
+	if mode == "dynamics" {
+		return FindVerticalDynamics
+	}
+	if mode == "greedy" {
+		return FindVerticalGreedy
+	}
+	return FindVerticalRandom
+}
+
+func (c *Carver) verticalIncrease(img image.Image, bias *pixelBias, noPixelsToIncrease int) (image.Image, *pixelBias, error) {
+	cv := c.newCarving(img, bias)
+	// The seams are inserted in the copy made by newCarving: removing them makes new images and leaves it untouched.
+	img = cv.img
+
+	vertical := make([][]int, noPixelsToIncrease)
+
+	for i := 0; i < noPixelsToIncrease; i++ {
+		vertical[i] = c.findSeam(cv)
+		c.removeSeam(cv, vertical[i])
+	}
+	magnitude := cv.magnitude
+
+	// Binary indexed trees for better complexity when finding the number of pixel after inserting stuff.
+	aib := make([][]int, magnitude.Height + 1)
+	for x := 0; x < magnitude.Height; x++ {
+		aib[x] = make([]int, magnitude.Width + 1 + noPixelsToIncrease)
+	}
+
+	for i := 0; i < noPixelsToIncrease; i++ {
+		if len(vertical[i]) != magnitude.Height {
+			return nil, nil, errors.New("vertical and magnitude has not the same value")
+		}
+		for line := range vertical[i] {
+			vertical[i][line] += askAib(aib[line], vertical[i][line])
+		}
+		img = increaseOneVertical(img, vertical[i])
+		bias = bias.increaseVertical(vertical[i])
+
+		for line := range vertical[i] {
+			updateAib(aib[line], vertical[i][line], 1)
+		}
+	}
+	return img, bias, nil
+}
+
+func updateAib(aib []int, poz int, val int) {
+	poz ++
+	for poz > 0 {
+		aib[poz] += val
+		poz -= poz & (-poz)
+	}
+}
+
+func askAib(aib []int, poz int) int {
+	ret := 0
+	// Aib is using interval [1, n]
+	poz ++
+	for poz < len(aib) {
+		ret += aib[poz]
+		poz += poz & (-poz)
+	}
+	return ret
+}
+
+func increaseOneVertical(srcImg image.Image, vertical []int) image.Image {
+	srcDim := srcImg.Bounds()
+	dstImage := meta.NewImageLike(srcImg, srcDim.Dx() + 1, srcDim.Dy())
+
+	// The lines of the images made by the lib are moved with copies, the other types pixel by pixel.
+	switch src := srcImg.(type) {
+	case *image.RGBA:
+		insertPixVertical(vertical, src.Pix, src.Stride, dstImage.(*image.RGBA).Pix, dstImage.(*image.RGBA).Stride, 4)
+	case *image.RGBA64:
+		insertPixVertical(vertical, src.Pix, src.Stride, dstImage.(*image.RGBA64).Pix, dstImage.(*image.RGBA64).Stride, 8)
+	default:
+		for y := 0; y < srcDim.Dy(); y++ {
+			for x := 0; x < srcDim.Dx(); x ++ {
+				if x < vertical[y] {
+					dstImage.Set(x, y, srcImg.At(x, y))
+				} else {
+					dstImage.Set(x+1, y, srcImg.At(x, y))
+				}
+			}
+		}
+	}
+
+	for y := 0; y < srcDim.Dy(); y++ {
+		if vertical[y] == 0 {
+			dstImage.Set(vertical[y], y, srcImg.At(vertical[y], y))
+			continue
+		}
+
+		// The values are premultiplied by alpha, so the color of a transparent neighbour does not leak in the new pixel.
+		rS, gS, bS, aS := srcImg.At(vertical[y] - 1, y).RGBA()
+		rD, gD, bD, aD := srcImg.At(vertical[y], y).RGBA()
+
+		pixel := color.RGBA64{
+			R: uint16((rS + rD + 1) >> 1),
+			G: uint16((gS + gD + 1) >> 1),
+			B: uint16((bS + bD + 1) >> 1),
+			A: uint16((aS + aD + 1) >> 1),
+		}
+
+		dstImage.Set(vertical[y], y, pixel)
+
+	}
+
+	return dstImage
+}
+
+func (c *Carver) verticalErase(img image.Image, bias *pixelBias, noPixelsToErase int) (image.Image, *pixelBias) {
+	cv := c.newCarving(img, bias)
+
+	for i := 0; i < noPixelsToErase; i++ {
+		c.removeSeam(cv, c.findSeam(cv))
+	}
+	return cv.img, cv.bias
+}
+
+// FindVerticalDynamics finds the vertical seam with the minimum sum of magnitude with dynamic programming.
+func FindVerticalDynamics(magnitude *meta.Plane) []int {
+	width := magnitude.Width
+	height := magnitude.Height
+	dyn := meta.NewPlane(width, height)
+	frm := make([]int, width * height)
+
+	copy(dyn.Row(0), magnitude.Row(0))
+	for y := 1; y < height; y++ {
+		prev := dyn.Row(y - 1)
+		cur := dyn.Row(y)
+		mag := magnitude.Row(y)
+		from := frm[y * width:(y + 1) * width]
+		for x := 0; x < width; x++ {
+			cur[x] = prev[x] + mag[x]
+			from[x] = x
+			if x != 0 && prev[x - 1] + mag[x] < cur[x] {
+				cur[x] = prev[x - 1] + mag[x]
+				from[x] = x - 1
+			}
+			if x != width - 1 && prev[x + 1] + mag[x] < cur[x] {
+				cur[x] = prev[x + 1] + mag[x]
+				from[x] = x + 1
+			}
+		}
+	}
+
+	lastP := 0
+	lastLine := dyn.Row(height - 1)
+	for x := 1; x < width; x ++ {
+		if lastLine[x] < lastLine[lastP] {
+			lastP = x
+		}
+	}
+
+	vertical := make([]int, height)
+	vertical[height - 1] = lastP
+	for y := height - 1; y > 0; y -- {
+		lastP = frm[y * width + lastP]
+		vertical[y - 1] = lastP
+	}
+	return vertical
+}
+
+// FindVerticalGreedy starts from the pixel with the minimum magnitude on the first line and always goes down to the
+// neighbour with the minimum magnitude.
+func FindVerticalGreedy(magnitude *meta.Plane) []int {
+	last := 0
+	for x := 1; x < magnitude.Width; x++ {
+		if magnitude.At(x, 0) < magnitude.At(last, 0) {
+			last = x
+		}
+	}
+	vertical := []int{last}
+	for y := 1; y < magnitude.Height; y++ {
+		next := last
+		if last != 0 && magnitude.At(last - 1, y) < magnitude.At(next, y) {
+			next = last - 1
+		}
+		if last != magnitude.Width - 1 && magnitude.At(last + 1, y) < magnitude.At(next, y) {
+			next = last + 1
+		}
+		vertical = append(vertical, next)
+		last = next
+	}
+	return vertical
+}
+
+// FindVerticalRandom returns a random connected vertical seam.
+func FindVerticalRandom(magnitude *meta.Plane) []int {
+	last := rand.Intn(magnitude.Width)
+	vertical := []int{last}
+	for y := 1; y < magnitude.Height; y++ {
+		next := last + rand.Intn(3) - 1
+		for next < 0 || next >= magnitude.Width {
+			next = last + rand.Intn(3) - 1
+		}
+		vertical = append(vertical, next)
+		last = next
+	}
+	return vertical
+}
+
+func deletePlaneVertical(vertical []int, plane *meta.Plane) *meta.Plane {
+	ret := meta.NewPlane(plane.Width - 1, plane.Height)
+	for line, indexDel := range vertical {
+		src := plane.Row(line)
+		dst := ret.Row(line)
+		copy(dst, src[:indexDel])
+		copy(dst[indexDel:], src[indexDel + 1:])
+	}
+	return ret
+}
+
+func deleteVertical(vertical []int, img image.Image, magnitude *meta.Plane) (image.Image, *meta.Plane)  {
+	retImg := meta.NewImageLike(img, magnitude.Width - 1, magnitude.Height)
+
+	// The lines of the images made by the lib are moved with copies, the other types pixel by pixel.
+	switch src := img.(type) {
+	case *image.RGBA:
+		deletePixVertical(vertical, src.Pix, src.Stride, retImg.(*image.RGBA).Pix, retImg.(*image.RGBA).Stride, 4)
+	case *image.RGBA64:
+		deletePixVertical(vertical, src.Pix, src.Stride, retImg.(*image.RGBA64).Pix, retImg.(*image.RGBA64).Stride, 8)
+	default:
+		for line, indexDel := range vertical {
+			for p := 0; p < indexDel; p ++ {
+				retImg.Set(p, line, img.At(p, line))
+			}
+			for p := indexDel; p < magnitude.Width - 1; p++ {
+				retImg.Set(p, line, img.At(p + 1, line))
+			}
+		}
+	}
+
+	return retImg, deletePlaneVertical(vertical, magnitude)
+}
+
+// insertPixVertical leaves a free pixel before the seam in the bytes of an image with the given bytes per pixel,
+// starting from (0, 0).
+func insertPixVertical(vertical []int, src []byte, srcStride int, dst []byte, dstStride int, size int) {
+	for line, indexAdd := range vertical {
+		srcLine := src[line * srcStride:line * srcStride + dstStride - size]
+		dstLine := dst[line * dstStride:line * dstStride + dstStride]
+		copy(dstLine, srcLine[:indexAdd * size])
+		copy(dstLine[(indexAdd + 1) * size:], srcLine[indexAdd * size:])
+	}
+}
+
+// deletePixVertical removes the seam from the bytes of an image with the given bytes per pixel, starting from (0, 0).
+func deletePixVertical(vertical []int, src []byte, srcStride int, dst []byte, dstStride int, size int) {
+	for line, indexDel := range vertical {
+		srcLine := src[line * srcStride:]
+		dstLine := dst[line * dstStride:line * dstStride + dstStride]
+		copy(dstLine, srcLine[:indexDel * size])
+		copy(dstLine[indexDel * size:], srcLine[(indexDel + 1) * size:(indexDel + 1) * size + dstStride - indexDel * size])
+	}
+}
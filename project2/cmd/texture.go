@@ -6,7 +6,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"image/jpeg"
 	"os"
 	"strconv"
 
@@ -26,34 +25,32 @@ func AddTextureToImage() *cobra.Command {
 		Args: cobra.ExactArgs(2),
 		RunE: func(_ *cobra.Command, args []string) error {
 			imgPath := args[0]
-			img, err := meta.GetImageFromPath(imgPath)
+			img, err := meta.DecodeImageFromPath(imgPath, decodeOptions())
 			if err != nil {
 				return errors.Wrapf(err, "could not get an image obj from path '%v'", imgPath)
 			}
 
 			imgPathTexture := args[1]
-			imgTexture, err := meta.GetImageFromPath(imgPathTexture)
+			imgTexture, err := meta.DecodeImageFromPath(imgPathTexture, decodeOptions())
 			if err != nil {
 				return errors.Wrapf(err, "could not get an image obj from path '%v'", imgPathTexture)
 			}
 
-			for step := 0; step < *stepsTexture; step++ {
-				fmt.Printf("begin step %v\n", step)
+			synthesizer, err := newSynthesizer()
+			if err != nil {
+				return errors.Wrapf(err, "could not configure the quilting")
+			}
 
-				blocks, err := getRandomBlocks(imgTexture, *noRandomBlocks, *lenBlockSquare, *lenOverlapSquares, *distanceFromBorder)
-				if err != nil {
-					return errors.Wrapf(err, "could not get the random blocks")
-				}
+			opts, err := encodeOptions()
+			if err != nil {
+				return err
+			}
+
+			for step := 0; step < *stepsTexture; step++ {
+				// The progress goes on stderr, so the result can be piped from the standard output.
+				fmt.Fprintf(os.Stderr, "begin step %v\n", step)
 
-				resultImg, err := createImage(
-					blocks,
-					img.Bounds().Dx(),
-					img.Bounds().Dy(),
-					*lenOverlapSquares,
-					*alphaTexture,
-					*typeAlgorithm,
-					img,
-				)
+				resultImg, err := synthesizer.Transfer(imgTexture, img, *alphaTexture)
 				if err != nil {
 					return errors.Wrapf(err, "could not create the image from blocks")
 				}
@@ -61,25 +58,29 @@ func AddTextureToImage() *cobra.Command {
 				// Set the resulted image as the texture for the future step.
 				imgTexture = resultImg
 
-				nameFile := *outputPath
-				lastDot := strings.LastIndex(nameFile, ".")
-
-				outFileName := nameFile[:lastDot] + strconv.Itoa(step) + nameFile[lastDot:]
-				fmt.Printf("%v\n", outFileName)
-
-				outFile, err := os.OpenFile(outFileName, os.O_WRONLY|os.O_CREATE, 0744)
-				if err != nil {
-					return errors.Wrapf(err, "could not create file at path '%v'", outFileName)
-				}
-
-				if err := jpeg.Encode(outFile, resultImg, nil); err != nil {
-					return errors.Wrapf(err, "could not encode image in jpeg format")
+				// Only the last step is written on the standard output.
+				if *outputPath != meta.StdStream || step == *stepsTexture - 1 {
+					outFileName := stepFileName(*outputPath, step)
+					fmt.Fprintf(os.Stderr, "%v\n", outFileName)
+					if err := meta.WriteImage(outFileName, resultImg, opts); err != nil {
+						return err
+					}
 				}
-				outFile.Close()
-				fmt.Printf("finished step %v\n", step)
+				fmt.Fprintf(os.Stderr, "finished step %v\n", step)
 			}
 			return nil
 		},
 	}
 	return command
-}
\ No newline at end of file
+}
+// stepFileName adds the step before the extension of the output path.
+func stepFileName(nameFile string, step int) string {
+	if nameFile == meta.StdStream {
+		return nameFile
+	}
+	lastDot := strings.LastIndex(nameFile, ".")
+	if lastDot <= strings.LastIndex(nameFile, "/") {
+		return nameFile + strconv.Itoa(step)
+	}
+	return nameFile[:lastDot] + strconv.Itoa(step) + nameFile[lastDot:]
+}
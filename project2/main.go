@@ -17,7 +17,7 @@ func main() {
 		)
 
 	if err := root.Execute(); err != nil {
-		fmt.Printf("error %v", err)
+		fmt.Fprintf(os.Stderr, "error %v\n", err)
 		os.Exit(1)
 	}
 }
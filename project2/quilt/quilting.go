@@ -0,0 +1,340 @@
+package quilt
+
+import (
+	"computer_vision/lib"
+	"github.com/pkg/errors"
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+type pair struct {
+	index int
+	error float64
+}
+
+type blockObj struct {
+	complete draw.Image
+	completeGray features
+	xMin     features
+	xMax     features
+	yMin     features
+	yMax     features
+}
+
+// features are the gray levels of a part of a block, or its color channels with Config.Color, with the opacities
+// when the texture is not opaque, so the transparent pixels do not match the black ones.
+type features struct {
+	channels []*meta.Plane
+	alpha    *meta.Plane
+}
+
+func (s *Synthesizer) newFeatures(img image.Image, withAlpha bool) features {
+	var ret features
+	if s.cfg.Color {
+		ret.channels = meta.ColorPlanes(img, s.cfg.ColorSpace)
+	} else {
+		ret.channels = []*meta.Plane{meta.GetGrayImage(img)}
+	}
+	if withAlpha {
+		ret.alpha = meta.GetAlphaImage(img)
+		if s.cfg.Color {
+			// The opacity is brought in [0, 1] like most of the color channels.
+			for i := range ret.alpha.Pix {
+				ret.alpha.Pix[i] /= 65535
+			}
+		}
+	}
+	return ret
+}
+
+// squaredErrors returns the squared difference of every pair of pixels, the opacities are compared only when both
+// have them.
+func squaredErrors(f1 features, f2 features) *meta.Plane {
+	ret := meta.NewPlane(f1.channels[0].Width, f1.channels[0].Height)
+	for y := 0; y < ret.Height; y++ {
+		row := ret.Row(y)
+		for c := range f1.channels {
+			channel1, channel2 := f1.channels[c].Row(y), f2.channels[c].Row(y)
+			for x := range row {
+				dif := channel1[x] - channel2[x]
+				row[x] += dif * dif
+			}
+		}
+		if f1.alpha != nil && f2.alpha != nil {
+			alpha1, alpha2 := f1.alpha.Row(y), f2.alpha.Row(y)
+			for x := range row {
+				dif := alpha1[x] - alpha2[x]
+				row[x] += dif * dif
+			}
+		}
+	}
+	return ret
+}
+
+// sumErrors is the sum of squaredErrors, without building the plane.
+func sumErrors(f1 features, f2 features) float64 {
+	ret := float64(0)
+	for c := range f1.channels {
+		for y := 0; y < f1.channels[c].Height; y++ {
+			channel2 := f2.channels[c].Row(y)
+			for x, val := range f1.channels[c].Row(y) {
+				dif := val - channel2[x]
+				ret += dif * dif
+			}
+		}
+	}
+	if f1.alpha != nil && f2.alpha != nil {
+		for y := 0; y < f1.alpha.Height; y++ {
+			alpha2 := f2.alpha.Row(y)
+			for x, val := range f1.alpha.Row(y) {
+				dif := val - alpha2[x]
+				ret += dif * dif
+			}
+		}
+	}
+	return ret
+}
+
+func (s *Synthesizer) getRandomBlocks(img image.Image) ([]blockObj, error) {
+	sizeBlock := s.cfg.BlockSize
+	overlap := s.cfg.Overlap
+	distanceBorder := s.cfg.DistanceBorder
+
+	if img.Bounds().Dx() - sizeBlock - 2 * distanceBorder <= 0 || img.Bounds().Dy() - sizeBlock - 2 * distanceBorder <= 0 {
+		return nil, errors.Errorf("image of %vx%v is too small for blocks of %v pixels at distance %v from the border",
+			img.Bounds().Dx(), img.Bounds().Dy(), sizeBlock, distanceBorder)
+	}
+
+	blocks := make([]blockObj, s.cfg.Candidates)
+	withAlpha := !meta.IsOpaque(img)
+
+	for blockIndex := 0; blockIndex < len(blocks); blockIndex++ {
+		up := s.rand.Intn(img.Bounds().Dx() - sizeBlock - 2 * distanceBorder) + distanceBorder
+		left := s.rand.Intn(img.Bounds().Dy() - sizeBlock - 2* distanceBorder) + distanceBorder
+
+		blocks[blockIndex].complete = defineBlockPart(up, left, sizeBlock, sizeBlock, img)
+		blocks[blockIndex].completeGray = s.newFeatures(blocks[blockIndex].complete, withAlpha)
+		blocks[blockIndex].xMin = s.newFeatures(defineBlockPart(up, left, overlap, sizeBlock, img), withAlpha)
+		blocks[blockIndex].yMin = s.newFeatures(defineBlockPart(up, left, sizeBlock, overlap, img), withAlpha)
+		blocks[blockIndex].xMax = s.newFeatures(defineBlockPart(up + sizeBlock - overlap, left, overlap, sizeBlock, img), withAlpha)
+		blocks[blockIndex].yMax = s.newFeatures(defineBlockPart(up, left + sizeBlock - overlap, sizeBlock, overlap, img), withAlpha)
+	}
+	return blocks, nil
+}
+
+func defineBlockPart(up int, left int, width int, length int, img image.Image) draw.Image {
+	bounds := img.Bounds()
+	ret := meta.NewImageLike(img, width, length)
+	for x := 0; x < width; x++ {
+		for y := 0; y < length; y++ {
+			ret.Set(x, y, img.At(bounds.Min.X + up + x, bounds.Min.Y + left + y))
+		}
+	}
+	return ret
+}
+
+func (s *Synthesizer) createImage(blocks []blockObj, width int, length int, alphaTexture float64, imgTr image.Image) draw.Image {
+	overlap := s.cfg.Overlap
+	retImg := meta.NewImageLike(blocks[0].complete, width, length)
+	blockSize := blocks[0].complete.Bounds().Dx()
+
+	imageBlockIndexPreviousLine := emptySplitSlice(width)
+
+	var imgTrForBlock draw.Image
+	if alphaTexture < 1 {
+		imgTrForBlock = meta.NewImageLike(imgTr, blockSize, blockSize)
+	}
+
+	var grayTrBlock features
+	withAlpha := blocks[0].completeGray.alpha != nil
+	x := 0
+	y := 0
+	for x < width {
+		y = 0
+		lenIndex := 0
+		leftBlock := -1
+		for y < length {
+			if alphaTexture < 1 {
+				trBounds := imgTr.Bounds()
+				for i := 0; i < blockSize; i++ {
+					for j := 0; j < blockSize; j++ {
+						imgTrForBlock.Set(i, j, imgTr.At(trBounds.Min.X + x + i, trBounds.Min.Y + y + j))
+					}
+				}
+				grayTrBlock = s.newFeatures(imgTrForBlock, withAlpha)
+			}
+
+			leftBlock = s.addBlockToImage(
+				x,
+				y,
+				blockSize,
+				imageBlockIndexPreviousLine[lenIndex],
+				leftBlock,
+				blocks,
+				retImg,
+				alphaTexture,
+				grayTrBlock,
+				)
+			imageBlockIndexPreviousLine[lenIndex] = leftBlock
+			y += blockSize - overlap
+			lenIndex++
+		}
+		x += blockSize - overlap
+	}
+
+	return retImg
+}
+
+func (s *Synthesizer) addBlockToImage(
+	xStart int,
+	yStart int,
+	blockSize int,
+	upLastBlock int,
+	leftLastBlock int,
+	blocks []blockObj,
+	img draw.Image,
+	alphaTexture float64,
+	imgTr features,
+	) int {
+	if upLastBlock == -1 && leftLastBlock == -1 {
+		firstBlock := s.rand.Intn(len(blocks))
+		for x := 0; x < blockSize; x++ {
+			for y := 0; y < blockSize; y++ {
+				img.Set(xStart + x, yStart + y, blocks[firstBlock].complete.At(x, y))
+			}
+		}
+		return firstBlock
+	}
+
+	minError := float64(math.MaxFloat64)
+	minBlock := -1
+
+	possibleBlocks := make([]pair, len(blocks))
+
+	for indexBlock := 0; indexBlock < len(blocks); indexBlock++ {
+		actualError := float64(0)
+		if upLastBlock != -1 {
+			actualError += sumErrors(blocks[upLastBlock].xMax, blocks[indexBlock].xMin)
+		}
+		if leftLastBlock != -1 {
+			actualError += sumErrors(blocks[leftLastBlock].yMax, blocks[indexBlock].yMin)
+		}
+		if alphaTexture < 1 {
+			actualError = alphaTexture * math.Sqrt(actualError) + (1 - alphaTexture) * differenceErrorImages(blocks[indexBlock].completeGray, imgTr)
+		}
+
+		if actualError < minError {
+			minError = actualError
+			minBlock = indexBlock
+		}
+
+		possibleBlocks[indexBlock] = pair{index: indexBlock, error: actualError}
+	}
+
+	sort.Slice(possibleBlocks, func(i, j int) bool {
+		return possibleBlocks[i].error < possibleBlocks[j].error
+	})
+
+	foundOkBlocks := 0
+	for foundOkBlocks < len(blocks) && possibleBlocks[foundOkBlocks].error <= s.cfg.Tolerance * minError {
+		foundOkBlocks++
+	}
+	// Keep at least the best block when all the errors are 0 or the tolerance is below 1.
+	if foundOkBlocks == 0 {
+		foundOkBlocks = 1
+	}
+
+	if s.cfg.Algorithm != RandomBlocks {
+		minBlock = possibleBlocks[s.rand.Intn(foundOkBlocks)].index
+	} else {
+		minBlock = possibleBlocks[s.rand.Intn(len(blocks))].index
+	}
+
+	var verticallySplit []int
+	var horizontallySplit []int
+
+	if s.cfg.Algorithm == MinimumErrorCut && leftLastBlock != -1 {
+		verticallySplit = findVerticallySplit(squaredErrors(blocks[leftLastBlock].yMax, blocks[minBlock].yMin))
+	} else {
+		verticallySplit = emptySplitSlice(blockSize)
+	}
+	if s.cfg.Algorithm == MinimumErrorCut && upLastBlock != -1 {
+		horizontallySplit = findHorizontallySplit(squaredErrors(blocks[upLastBlock].xMax, blocks[minBlock].xMin))
+	} else {
+		horizontallySplit = emptySplitSlice(blockSize)
+	}
+
+	for x := 0; x < blockSize; x++ {
+		for y := verticallySplit[x] + 1; y < blockSize; y++ {
+			if x <= horizontallySplit[y] {
+				continue
+			}
+			img.Set(xStart + x, yStart + y, blocks[minBlock].complete.At(x, y))
+		}
+	}
+
+	return minBlock
+}
+
+func differenceErrorImages(img1 features, img2 features) float64 {
+	return math.Sqrt(sumErrors(img1, img2))
+}
+
+func emptySplitSlice(len int) []int {
+	ret := make([]int, len)
+	for i := 0; i < len; i++ {
+		ret[i] = -1
+	}
+	return ret
+}
+
+func findHorizontallySplit(overlapErrors *meta.Plane) []int {
+	return findVerticallySplit(overlapErrors.Transpose())
+}
+
+// findVerticallySplit returns the cut of minimum error through the overlap, given the squared error of every pixel.
+// The cut goes along x, it has the y of the last pixel kept on the side of the previous block for every x.
+func findVerticallySplit(overlapErrors *meta.Plane) []int {
+	width := overlapErrors.Width
+	height := overlapErrors.Height
+	dyn := meta.NewPlane(width, height)
+	frm := make([]int, width * height)
+
+	for y := 0; y < height; y++ {
+		dyn.Set(0, y, overlapErrors.At(0, y))
+	}
+
+	for x := 1; x < width; x++ {
+		for y := 0; y < height; y++ {
+			best := dyn.At(x - 1, y)
+			from := y
+			if y != 0 && dyn.At(x - 1, y - 1) < best {
+				best = dyn.At(x - 1, y - 1)
+				from = y - 1
+			}
+			if y != height - 1 && dyn.At(x - 1, y + 1) < best {
+				best = dyn.At(x - 1, y + 1)
+				from = y + 1
+			}
+			dyn.Set(x, y, best + overlapErrors.At(x, y))
+			frm[y * width + x] = from
+		}
+	}
+
+	lastP := 0
+	for y := 0; y < height; y ++ {
+		if dyn.At(width - 1, y) < dyn.At(width - 1, lastP) {
+			lastP = y
+		}
+	}
+
+	vertical := make([]int, width)
+	vertical[width - 1] = lastP
+	for x := width - 1; x > 0; x -- {
+		lastP = frm[lastP * width + x]
+		vertical[x - 1] = lastP
+	}
+	return vertical
+}
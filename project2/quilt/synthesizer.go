@@ -0,0 +1,113 @@
+// Package quilt implements the image quilting from https://people.eecs.berkeley.edu/~efros/research/quilting/quilting.pdf
+// for texture synthesis and texture transfer, without any file or console side effects.
+package quilt
+
+import (
+	"computer_vision/lib"
+	"github.com/pkg/errors"
+	"image"
+	"math/rand"
+	"time"
+)
+
+// Algorithm is the way a new block is chosen and pasted over its neighbours.
+type Algorithm int
+
+const (
+	// RandomBlocks places all the time completely random blocks.
+	RandomBlocks Algorithm = iota
+	// MinimumError takes a block with an acceptable error of overlap with the neighbours.
+	MinimumError
+	// MinimumErrorCut takes a block with an acceptable error and calculates a frontier for the best overlap.
+	MinimumErrorCut
+)
+
+// Config configures a Synthesizer. DefaultConfig gives the defaults of the cli, NewSynthesizer takes the values as
+// they are and rejects the ones out of range.
+type Config struct {
+	// BlockSize is the number of pixels in length of each block square.
+	BlockSize int
+	// Overlap is the number of pixels in length of the overlap between two consecutive blocks.
+	Overlap int
+	// Tolerance is the factor over the minimum overlap error up to which a block is acceptable.
+	Tolerance float64
+	Algorithm Algorithm
+	// Candidates is the number of random blocks taken from the source between which the next block is chosen.
+	Candidates int
+	// DistanceBorder is the minimum distance of the random blocks from the border of the source image.
+	DistanceBorder int
+	// Color compares the blocks, and the blocks with the target of a transfer, on the three channels of ColorSpace
+	// instead of the luminance. The hue of HSV is compared as a line, not as a circle.
+	Color      bool
+	ColorSpace meta.ColorSpace
+	// Rand is the source of all the random choices, seeded with the current time if nil.
+	Rand *rand.Rand
+}
+
+// Synthesizer builds new images by quilting blocks of a source texture.
+type Synthesizer struct {
+	cfg  Config
+	rand *rand.Rand
+}
+
+// DefaultConfig returns the configuration of the cli without any flag.
+func DefaultConfig() Config {
+	return Config{
+		BlockSize:  36,
+		Overlap:    6,
+		Tolerance:  1.1,
+		Algorithm:  MinimumErrorCut,
+		Candidates: 5000,
+	}
+}
+
+func NewSynthesizer(cfg Config) (*Synthesizer, error) {
+	if cfg.Rand == nil {
+		cfg.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	if cfg.BlockSize <= 0 || cfg.Candidates <= 0 {
+		return nil, errors.Errorf("the block size %v and the number of candidates %v should be positive",
+			cfg.BlockSize, cfg.Candidates)
+	}
+	if cfg.Overlap < 0 || cfg.DistanceBorder < 0 || cfg.Tolerance < 0 {
+		return nil, errors.Errorf("negative values in config %+v", cfg)
+	}
+	if cfg.Overlap >= cfg.BlockSize {
+		return nil, errors.Errorf("overlap %v should be smaller than the block size %v", cfg.Overlap, cfg.BlockSize)
+	}
+	if cfg.Algorithm < RandomBlocks || cfg.Algorithm > MinimumErrorCut {
+		return nil, errors.Errorf("unknown algorithm %v", cfg.Algorithm)
+	}
+
+	return &Synthesizer{cfg: cfg, rand: cfg.Rand}, nil
+}
+
+// Synthesize creates a new image of width x height pixels from blocks of the src texture.
+func (s *Synthesizer) Synthesize(src image.Image, width int, height int) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, errors.Errorf("could not synthesize an image of %vx%v pixels", width, height)
+	}
+
+	blocks, err := s.getRandomBlocks(src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get the random blocks")
+	}
+
+	return s.createImage(blocks, width, height, 1, nil), nil
+}
+
+// Transfer redraws the target image with blocks of the texture. Alpha is the weight of the overlap error against the
+// difference from the target, 1 ignores the target completely.
+func (s *Synthesizer) Transfer(texture image.Image, target image.Image, alpha float64) (image.Image, error) {
+	if alpha < 0 || alpha > 1 {
+		return nil, errors.Errorf("alpha %v is not in the [0, 1] interval", alpha)
+	}
+
+	blocks, err := s.getRandomBlocks(texture)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get the random blocks")
+	}
+
+	return s.createImage(blocks, target.Bounds().Dx(), target.Bounds().Dy(), alpha, target), nil
+}